@@ -0,0 +1,86 @@
+package cache_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/pavankalyan767/exchange-rate-service/cache"
+	"github.com/pavankalyan767/exchange-rate-service/types"
+)
+
+// benchmarkDays is the number of days cached in the benchmarks, about a year of history.
+const benchmarkDays = 365
+
+// benchmarkCaches builds a rate cache of each implementation, filled with a
+// year of rate tables keyed like the service's: "day-0" stands for today.
+func benchmarkCaches(b *testing.B) map[string]*cache.RateCache {
+	caches := map[string]*cache.RateCache{
+		"Cache":        cache.NewRateCache(time.Hour, time.Hour, log.NewNopLogger()),
+		"ShardedCache": cache.NewRateCache(time.Hour, time.Hour, log.NewNopLogger(), cache.WithShards(16)),
+	}
+	table := types.RateTable{"USDINR": 83, "USDEUR": 0.91, "USDJPY": 148.2, "USDGBP": 0.79}
+	for _, c := range caches {
+		for day := range benchmarkDays {
+			c.Set("day-"+strconv.Itoa(day), table, time.Hour)
+		}
+		b.Cleanup(c.Close)
+	}
+	return caches
+}
+
+// BenchmarkGetToday reads the same key from every goroutine, as /fetch and
+// /convert do for today's rates.
+func BenchmarkGetToday(b *testing.B) {
+	for name, c := range benchmarkCaches(b) {
+		b.Run(name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					c.GetRateWithDate("day-0", "USDINR")
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkGetHistory reads every day of a 30-day range per operation, as
+// /history does.
+func BenchmarkGetHistory(b *testing.B) {
+	keys := make([]string, 30)
+	for day := range keys {
+		keys[day] = "day-" + strconv.Itoa(day)
+	}
+	for name, c := range benchmarkCaches(b) {
+		b.Run(name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					for _, key := range keys {
+						c.GetRateWithDate(key, "USDINR")
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkMixed interleaves one write of today's rates with every 99 reads
+// spread across the year.
+func BenchmarkMixed(b *testing.B) {
+	table := types.RateTable{"USDINR": 84}
+	for name, c := range benchmarkCaches(b) {
+		b.Run(name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					if i%100 == 0 {
+						c.Set("day-0", table, time.Hour)
+					} else {
+						c.GetRateWithDate("day-"+strconv.Itoa(i%benchmarkDays), "USDINR")
+					}
+					i++
+				}
+			})
+		})
+	}
+}
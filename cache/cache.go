@@ -2,105 +2,409 @@ package cache
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
 	"github.com/go-kit/log"
+	"github.com/pavankalyan767/exchange-rate-service/types"
 )
 
-type Cache struct {
-	data        map[string]interface{}
-	expiration  map[string]time.Time
+// Sizer is implemented by values that can estimate their memory footprint in
+// bytes. A byte budget only accounts for values implementing it.
+type Sizer interface {
+	Size() int
+}
+
+// Interface is the API shared by Cache and ShardedCache.
+type Interface[K comparable, V any] interface {
+	Set(key K, value V, ttl time.Duration)
+	SetAt(key K, value V, ttl time.Duration, updated time.Time)
+	Get(key K) (V, bool)
+	Lookup(key K) (Item[V], bool)
+	Delete(key K)
+	Keys() []K
+	Len() int
+	Close()
+}
+
+var (
+	_ Interface[string, int] = (*Cache[string, int])(nil)
+	_ Interface[string, int] = (*ShardedCache[string, int])(nil)
+)
+
+// Option configures optional Cache and ShardedCache behaviour.
+type Option func(*settings)
+
+type settings struct {
+	maxEntries int
+	maxBytes   int
+	maxStale   time.Duration
+	shards     int
+
+	name      string
+	lookups   metrics.Counter // labels: cache, result
+	counts    lookupCounters
+	evictions metrics.Counter // labels: cache, reason
+	entries   metrics.Gauge   // labels: cache
+	bytes     metrics.Gauge   // labels: cache
+}
+
+// WithMaxEntries bounds the cache to n entries; 0 means unbounded. Once full,
+// adding an entry evicts the least recently used one.
+func WithMaxEntries(n int) Option {
+	return func(s *settings) {
+		s.maxEntries = n
+	}
+}
+
+// WithMaxBytes bounds the estimated size of the cached values (see Sizer);
+// 0 means unbounded. Least recently used entries are evicted to stay within it.
+func WithMaxBytes(n int) Option {
+	return func(s *settings) {
+		s.maxBytes = n
+	}
+}
+
+// WithShards sets the number of shards of a ShardedCache, and makes
+// NewRateCache build one when n is greater than 1. Cache ignores it.
+func WithShards(n int) Option {
+	return func(s *settings) {
+		s.shards = n
+	}
+}
+
+// WithMaxStale keeps entries for up to d past their TTL. Get no longer returns
+// them, but Lookup does, flagged as stale, so callers can keep serving the last
+// known value while it is being refreshed.
+func WithMaxStale(d time.Duration) Option {
+	return func(s *settings) {
+		s.maxStale = d
+	}
+}
+
+// WithMetrics reports the cache, under the given name, on the lookup counter
+// (labels: cache, result, where result is "hit", "stale", "expired" or "miss"),
+// on the eviction counter (labels: cache, reason, where reason is "capacity"
+// or "expired") and on the gauges of its current entry count and byte size
+// (labels: cache).
+func WithMetrics(name string, lookups, evictions metrics.Counter, entries, bytes metrics.Gauge) Option {
+	return func(s *settings) {
+		s.name = name
+		s.lookups = lookups
+		s.evictions = evictions
+		s.entries = entries
+		s.bytes = bytes
+	}
+}
+
+// lookupCounters are the lookup counter bound to each result, so that reads
+// do not build label lists.
+type lookupCounters struct {
+	hit, stale, expired, miss metrics.Counter
+}
+
+// bindMetrics binds the lookup counter to the cache name once options are applied.
+func (s *settings) bindMetrics() {
+	s.counts = lookupCounters{
+		hit:     s.lookups.With("cache", s.name, "result", "hit"),
+		stale:   s.lookups.With("cache", s.name, "result", "stale"),
+		expired: s.lookups.With("cache", s.name, "result", "expired"),
+		miss:    s.lookups.With("cache", s.name, "result", "miss"),
+	}
+}
+
+// entry is a cached value.
+type entry[K comparable, V any] struct {
+	key        K
+	value      V
+	updated    time.Time
+	expiration time.Time
+	size       int
+	lastUsed   atomic.Uint64 // cache clock at the last read or write
+}
+
+// expired reports whether the entry is past its TTL.
+func (e *entry[K, V]) expired(now time.Time) bool {
+	return now.After(e.expiration)
+}
+
+// Item is a value read with Lookup.
+type Item[V any] struct {
+	Value V
+	// Age is the time since the value was last updated.
+	Age time.Duration
+	// Stale is set when the value is past its TTL but still within the
+	// WithMaxStale allowance.
+	Stale bool
+}
+
+// Cache is an in-memory key/value store whose entries expire after a TTL and,
+// when bounded, are evicted in least recently used order. It is safe for
+// concurrent use. Reads only take a shared lock: like ShardedCache, a read
+// stamps the entry with a logical clock instead of reordering a recency list,
+// and eviction, which only happens on writes, drops the entry with the oldest
+// stamp.
+type Cache[K comparable, V any] struct {
+	settings
+
+	data        map[K]*entry[K, V]
+	clock       atomic.Uint64
+	size        int
 	mutex       sync.RWMutex
 	defaultTTL  time.Duration
 	cleanupTick time.Duration
 	logger      log.Logger
+
+	stop      chan struct{} // closed by Close
+	done      chan struct{} // closed when the cleanup goroutine exits
+	closeOnce sync.Once
 }
 
 // NewCache is a constructor for the Cache struct.
 // can be used for both live and historical data.
-func NewCache(defaultTTL, cleanupTick time.Duration, logger log.Logger) *Cache {
-	cache := &Cache{
-		data:        make(map[string]interface{}),
-		expiration:  make(map[string]time.Time),
+// The caller must Close the cache to stop its cleanup goroutine.
+func NewCache[K comparable, V any](defaultTTL, cleanupTick time.Duration, logger log.Logger, opts ...Option) *Cache[K, V] {
+	cache := &Cache[K, V]{
+		settings: settings{
+			lookups:   discard.NewCounter(),
+			evictions: discard.NewCounter(),
+			entries:   discard.NewGauge(),
+			bytes:     discard.NewGauge(),
+		},
+		data:        make(map[K]*entry[K, V]),
 		defaultTTL:  defaultTTL,
 		cleanupTick: cleanupTick,
 		logger:      logger,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&cache.settings)
 	}
+	cache.bindMetrics()
 
 	go cache.startCleanup()
 
 	return cache
 }
 
-// background goroutine that periodically cleansup expired items .
-func (c *Cache) startCleanup() {
+// Close stops the background cleanup and waits for it to exit. The cache
+// remains usable, but expired entries are no longer removed until they are
+// replaced or deleted. Close may be called more than once.
+func (c *Cache[K, V]) Close() {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+	<-c.done
+}
+
+// background goroutine that periodically cleansup expired items until Close is called.
+func (c *Cache[K, V]) startCleanup() {
+	defer close(c.done)
 	ticker := time.NewTicker(c.cleanupTick)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ticker.C:
 			c.cleanup()
+		case <-c.stop:
+			return
 		}
 	}
 }
 
 // cleanup iterates through the cache and removes expired items.
-func (c *Cache) cleanup() {
+func (c *Cache[K, V]) cleanup() {
 	currentTime := time.Now()
 
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	for key, expirationTime := range c.expiration {
-		if currentTime.After(expirationTime) {
-			delete(c.data, key)
-			delete(c.expiration, key)
+	for _, e := range c.data {
+		if currentTime.After(e.expiration.Add(c.maxStale)) {
+			c.remove(e)
+			c.evictions.With("cache", c.name, "reason", "expired").Add(1)
 		}
 	}
+	c.updateGauges()
 }
 
 // Set adds a key-value pair to the cache with a given TTL.
-func (c *Cache) Set(key string, value interface{}, ttl time.Duration) {
+func (c *Cache[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.SetAt(key, value, ttl, time.Now())
+}
+
+// SetAt is like Set for a value that was last updated at the given time, such
+// as one reloaded from disk. The TTL still runs from now; only the age that
+// Lookup reports is measured from updated.
+func (c *Cache[K, V]) SetAt(key K, value V, ttl time.Duration, updated time.Time) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	c.data[key] = value
-	c.expiration[key] = time.Now().Add(ttl)
+	if e, ok := c.data[key]; ok {
+		c.remove(e)
+	}
+	e := &entry[K, V]{key: key, value: value, updated: updated, expiration: time.Now().Add(ttl), size: sizeOf(value)}
+	e.lastUsed.Store(c.clock.Add(1))
+	c.data[key] = e
+	c.size += e.size
+
+	c.evict(key)
+	c.updateGauges()
 }
 
-// Get retrieves a value from the cache.
-func (c *Cache) Get(key string) (interface{}, bool) {
+// Delete removes a key from the cache.
+func (c *Cache[K, V]) Delete(key K) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if e, ok := c.data[key]; ok {
+		c.remove(e)
+		c.updateGauges()
+	}
+}
+
+// Keys returns the keys of all unexpired entries, in no particular order.
+func (c *Cache[K, V]) Keys() []K {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	value, ok := c.data[key]
-	if !ok || time.Now().After(c.expiration[key]) {
-		return nil, false // Return nil and false if the key is not found or is expired
+	now := time.Now()
+	keys := make([]K, 0, len(c.data))
+	for key, e := range c.data {
+		if !e.expired(now) {
+			keys = append(keys, key)
+		}
 	}
+	return keys
+}
+
+// Len returns the number of entries held, including expired and stale ones not yet cleaned up.
+func (c *Cache[K, V]) Len() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 
-	return value, true
+	return len(c.data)
 }
 
-// GetLiveRate retrieves a live rate and returns it as a float64.
-func (c *Cache) GetRateWithDate(date string, currencyPair string) (float64, bool) {
-	// First, retrieve the entire map of rates for the given date.
-	val, ok := c.Get(date)
+// Get retrieves a value from the cache and marks it as recently used.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	e, ok := c.data[key]
 	if !ok {
-		// No data found for this date.
-		return 0, false
+		c.counts.miss.Add(1)
+		var zero V
+		return zero, false // Return the zero value and false if the key is not found or is expired
+	}
+	if e.expired(time.Now()) {
+		c.counts.expired.Add(1)
+		var zero V
+		return zero, false
 	}
 
-	// Now, perform a type assertion to get the map of rates.
-	ratesMap, ok := val.(map[string]float64)
+	c.counts.hit.Add(1)
+	e.lastUsed.Store(c.clock.Add(1))
+	return e.value, true
+}
+
+// Lookup is like Get, but also returns entries past their TTL for as long as
+// WithMaxStale allows, flagged as stale, and reports the value's age.
+func (c *Cache[K, V]) Lookup(key K) (Item[V], bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	now := time.Now()
+	e, ok := c.data[key]
 	if !ok {
-		// The cached value is not a map[string]float64. This indicates a data integrity issue.
-		c.logger.Log("Error: Value for date '%s' is not a map[string]float64.\n", date)
-		return 0, false
+		c.counts.miss.Add(1)
+		return Item[V]{}, false
+	}
+	if now.After(e.expiration.Add(c.maxStale)) {
+		c.counts.expired.Add(1)
+		return Item[V]{}, false
 	}
 
-	// Finally, get the specific currency pair from the map.
-	rate, ok := ratesMap[currencyPair]
-	c.logger.Log("Retrieved rate:", rate, "for currency pair:", currencyPair)
-	return rate, ok
+	item := Item[V]{Value: e.value, Age: now.Sub(e.updated), Stale: e.expired(now)}
+	if item.Stale {
+		c.counts.stale.Add(1)
+	} else {
+		c.counts.hit.Add(1)
+	}
+	e.lastUsed.Store(c.clock.Add(1))
+	return item, true
 }
 
-// GetHistoryRates retrieves historical rates and returns them as a map.
+// evict drops least recently used entries until the cache is within its
+// bounds. The entry just set, keep, is never evicted. Finding the oldest entry
+// scans the cache, which only writes pay for. The caller must hold the write
+// lock.
+func (c *Cache[K, V]) evict(keep K) {
+	for len(c.data) > 1 && c.overBudget() {
+		var oldest *entry[K, V]
+		for key, e := range c.data {
+			if key != keep && (oldest == nil || e.lastUsed.Load() < oldest.lastUsed.Load()) {
+				oldest = e
+			}
+		}
+		c.remove(oldest)
+		c.evictions.With("cache", c.name, "reason", "capacity").Add(1)
+	}
+}
+
+func (c *Cache[K, V]) overBudget() bool {
+	return (c.maxEntries > 0 && len(c.data) > c.maxEntries) || (c.maxBytes > 0 && c.size > c.maxBytes)
+}
+
+// remove drops an entry. The caller must hold the write lock.
+func (c *Cache[K, V]) remove(e *entry[K, V]) {
+	delete(c.data, e.key)
+	c.size -= e.size
+}
+
+// updateGauges reports the current size. The caller must hold the write lock.
+func (c *Cache[K, V]) updateGauges() {
+	c.entries.With("cache", c.name).Set(float64(len(c.data)))
+	c.bytes.With("cache", c.name).Set(float64(c.size))
+}
+
+func sizeOf(value any) int {
+	if sizer, ok := value.(Sizer); ok {
+		return sizer.Size()
+	}
+	return 0
+}
+
+// RateCache holds one RateTable per date, keyed in internal.DateFormat.
+type RateCache struct {
+	Interface[string, types.RateTable]
+}
+
+// NewRateCache creates an empty RateCache, backed by a ShardedCache when
+// WithShards asks for more than one shard and by a Cache otherwise.
+func NewRateCache(defaultTTL, cleanupTick time.Duration, logger log.Logger, opts ...Option) *RateCache {
+	var s settings
+	for _, opt := range opts {
+		opt(&s)
+	}
+	if s.shards > 1 {
+		return &RateCache{NewShardedCache[string, types.RateTable](defaultTTL, cleanupTick, logger, opts...)}
+	}
+	return &RateCache{NewCache[string, types.RateTable](defaultTTL, cleanupTick, logger, opts...)}
+}
+
+// GetRateWithDate retrieves the rate of a currency pair on the given date.
+func (c *RateCache) GetRateWithDate(date string, currencyPair string) (float64, bool) {
+	// First, retrieve the entire table of rates for the given date.
+	rates, ok := c.Get(date)
+	if !ok {
+		// No data found for this date.
+		return 0, false
+	}
+
+	// Then get the specific currency pair from the table.
+	return rates.Rate(currencyPair)
+}
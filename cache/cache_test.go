@@ -0,0 +1,164 @@
+package cache_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/log"
+	"github.com/pavankalyan767/exchange-rate-service/cache"
+	"github.com/pavankalyan767/exchange-rate-service/types"
+)
+
+// implementations builds a cache of each implementation. The sharded one has
+// a single shard, so that its eviction order is exact.
+var implementations = map[string]func(opts ...cache.Option) cache.Interface[string, int]{
+	"Cache": func(opts ...cache.Option) cache.Interface[string, int] {
+		return cache.NewCache[string, int](time.Minute, time.Minute, log.NewNopLogger(), opts...)
+	},
+	"ShardedCache": func(opts ...cache.Option) cache.Interface[string, int] {
+		return cache.NewShardedCache[string, int](time.Minute, time.Minute, log.NewNopLogger(), append([]cache.Option{cache.WithShards(1)}, opts...)...)
+	},
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	for name, newCache := range implementations {
+		t.Run(name, func(t *testing.T) {
+			c := newCache(cache.WithMaxEntries(2))
+			defer c.Close()
+
+			c.Set("a", 1, time.Minute)
+			c.Set("b", 2, time.Minute)
+			c.Get("a") // a is now more recently used than b
+			c.Set("c", 3, time.Minute)
+
+			if _, ok := c.Get("b"); ok {
+				t.Errorf("expected b, the least recently used entry, to be evicted")
+			}
+			for _, key := range []string{"a", "c"} {
+				if _, ok := c.Get(key); !ok {
+					t.Errorf("expected %s to be kept", key)
+				}
+			}
+			if c.Len() != 2 {
+				t.Errorf("expected 2 entries, got %d", c.Len())
+			}
+		})
+	}
+}
+
+func TestCache_EvictsToStayWithinByteBudget(t *testing.T) {
+	table := types.RateTable{"USDINR": 83, "USDEUR": 0.91}
+	c := cache.NewRateCache(time.Minute, time.Minute, log.NewNopLogger(), cache.WithMaxBytes(2*table.Size()))
+	defer c.Close()
+
+	for _, date := range []string{"2025-08-12", "2025-08-13", "2025-08-14"} {
+		c.Set(date, table, time.Minute)
+	}
+
+	if _, ok := c.Get("2025-08-12"); ok {
+		t.Errorf("expected the oldest day to be evicted")
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries within the budget, got %d", c.Len())
+	}
+}
+
+func TestCache_CloseStopsCleanup(t *testing.T) {
+	c := cache.NewCache[string, int](time.Millisecond, time.Millisecond, log.NewNopLogger())
+	c.Close()
+	c.Close() // closing twice is allowed
+
+	c.Set("a", 1, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	if c.Len() != 1 {
+		t.Errorf("expected the expired entry to stay after Close, got %d entries", c.Len())
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("expected the expired entry to be unreadable")
+	}
+}
+
+func TestCache_LookupServesStaleEntries(t *testing.T) {
+	for name, newCache := range implementations {
+		t.Run(name, func(t *testing.T) {
+			c := newCache(cache.WithMaxStale(time.Hour))
+			defer c.Close()
+
+			c.SetAt("a", 1, -time.Second, time.Now().Add(-time.Minute))
+
+			if _, ok := c.Get("a"); ok {
+				t.Errorf("expected Get to miss an expired entry")
+			}
+			item, ok := c.Lookup("a")
+			if !ok || item.Value != 1 || !item.Stale {
+				t.Fatalf("expected Lookup to return the stale entry, got %+v (ok %v)", item, ok)
+			}
+			if item.Age < time.Minute {
+				t.Errorf("expected an age of at least 1m, got %s", item.Age)
+			}
+		})
+	}
+}
+
+// countingCounter records the total added per label set, keyed by the joined label values.
+type countingCounter struct {
+	counts map[string]float64
+	key    string
+}
+
+func (c *countingCounter) With(labelValues ...string) metrics.Counter {
+	return &countingCounter{counts: c.counts, key: strings.Join(labelValues, ",")}
+}
+
+func (c *countingCounter) Add(delta float64) {
+	c.counts[c.key] += delta
+}
+
+func TestCache_CountsLookups(t *testing.T) {
+	for name, newCache := range implementations {
+		t.Run(name, func(t *testing.T) {
+			lookups := &countingCounter{counts: map[string]float64{}}
+			c := newCache(
+				cache.WithMaxStale(time.Hour),
+				cache.WithMetrics("fiat", lookups, discard.NewCounter(), discard.NewGauge(), discard.NewGauge()))
+			defer c.Close()
+
+			c.Set("fresh", 1, time.Minute)
+			c.Set("old", 2, -time.Second)
+			c.Get("fresh")
+			c.Get("old")
+			c.Get("absent")
+			c.Lookup("old")
+
+			for key, expected := range map[string]float64{
+				"cache,fiat,result,hit":     1,
+				"cache,fiat,result,expired": 1,
+				"cache,fiat,result,miss":    1,
+				"cache,fiat,result,stale":   1,
+			} {
+				if lookups.counts[key] != expected {
+					t.Errorf("expected %s to be %v, got %v", key, expected, lookups.counts[key])
+				}
+			}
+		})
+	}
+}
+
+func TestShardedCache_SplitsBoundsAcrossShards(t *testing.T) {
+	c := cache.NewShardedCache[int, int](time.Minute, time.Minute, log.NewNopLogger(), cache.WithShards(4), cache.WithMaxEntries(8))
+	defer c.Close()
+
+	for i := range 100 {
+		c.Set(i, i, time.Minute)
+	}
+	if c.Len() > 8 {
+		t.Errorf("expected at most 8 entries, got %d", c.Len())
+	}
+	if _, ok := c.Get(99); !ok {
+		t.Errorf("expected the last entry set to be kept")
+	}
+}
@@ -0,0 +1,273 @@
+package cache
+
+import (
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/log"
+)
+
+// defaultShards is the number of shards of a ShardedCache when WithShards is not given.
+const defaultShards = 16
+
+// ShardedCache has the API of Cache, but spreads its entries over shards that
+// are locked independently, and reads only take a shared lock: instead of
+// moving entries in a recency list, a read stamps the entry with a per-shard
+// logical clock, and eviction drops the entry with the oldest stamp. Reads of
+// the same key, such as today's rates, therefore no longer serialise.
+//
+// Bounds are split evenly across shards, so least recently used order holds
+// within a shard rather than across the whole cache.
+type ShardedCache[K comparable, V any] struct {
+	settings
+
+	seed   maphash.Seed
+	shards []*shard[K, V]
+
+	defaultTTL  time.Duration
+	cleanupTick time.Duration
+	logger      log.Logger
+
+	stop      chan struct{} // closed by Close
+	done      chan struct{} // closed when the cleanup goroutine exits
+	closeOnce sync.Once
+}
+
+type shard[K comparable, V any] struct {
+	mutex sync.RWMutex
+	data  map[K]*shardEntry[V]
+	size  int
+	clock atomic.Uint64
+
+	maxEntries int
+	maxBytes   int
+}
+
+type shardEntry[V any] struct {
+	value      V
+	updated    time.Time
+	expiration time.Time
+	size       int
+	lastUsed   atomic.Uint64 // shard clock at the last read or write
+}
+
+// NewShardedCache creates an empty ShardedCache with the shard count given by
+// WithShards, or 16. The caller must Close the cache to stop its cleanup goroutine.
+func NewShardedCache[K comparable, V any](defaultTTL, cleanupTick time.Duration, logger log.Logger, opts ...Option) *ShardedCache[K, V] {
+	c := &ShardedCache[K, V]{
+		settings: settings{
+			shards:    defaultShards,
+			lookups:   discard.NewCounter(),
+			evictions: discard.NewCounter(),
+			entries:   discard.NewGauge(),
+			bytes:     discard.NewGauge(),
+		},
+		seed:        maphash.MakeSeed(),
+		defaultTTL:  defaultTTL,
+		cleanupTick: cleanupTick,
+		logger:      logger,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&c.settings)
+	}
+	c.bindMetrics()
+
+	n := max(c.settings.shards, 1)
+	c.shards = make([]*shard[K, V], n)
+	for i := range c.shards {
+		c.shards[i] = &shard[K, V]{
+			data:       make(map[K]*shardEntry[V]),
+			maxEntries: ceilDiv(c.maxEntries, n),
+			maxBytes:   ceilDiv(c.maxBytes, n),
+		}
+	}
+
+	go c.startCleanup()
+
+	return c
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
+
+func (c *ShardedCache[K, V]) shardFor(key K) *shard[K, V] {
+	return c.shards[maphash.Comparable(c.seed, key)%uint64(len(c.shards))]
+}
+
+// Close stops the background cleanup and waits for it to exit. Close may be
+// called more than once.
+func (c *ShardedCache[K, V]) Close() {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+	<-c.done
+}
+
+// background goroutine that periodically cleans up every shard until Close is called.
+func (c *ShardedCache[K, V]) startCleanup() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.cleanupTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, s := range c.shards {
+				c.cleanup(s)
+			}
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// cleanup removes the entries of s that are past their TTL and stale allowance.
+func (c *ShardedCache[K, V]) cleanup(s *shard[K, V]) {
+	currentTime := time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key, e := range s.data {
+		if currentTime.After(e.expiration.Add(c.maxStale)) {
+			c.remove(s, key, e)
+			c.evictions.With("cache", c.name, "reason", "expired").Add(1)
+		}
+	}
+}
+
+// Set adds a key-value pair to the cache with a given TTL.
+func (c *ShardedCache[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.SetAt(key, value, ttl, time.Now())
+}
+
+// SetAt is like Set for a value that was last updated at the given time; see Cache.SetAt.
+func (c *ShardedCache[K, V]) SetAt(key K, value V, ttl time.Duration, updated time.Time) {
+	s := c.shardFor(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if e, ok := s.data[key]; ok {
+		c.remove(s, key, e)
+	}
+	e := &shardEntry[V]{value: value, updated: updated, expiration: time.Now().Add(ttl), size: sizeOf(value)}
+	e.lastUsed.Store(s.clock.Add(1))
+	s.data[key] = e
+	s.size += e.size
+	c.entries.With("cache", c.name).Add(1)
+	c.bytes.With("cache", c.name).Add(float64(e.size))
+
+	c.evict(s, key)
+}
+
+// Delete removes a key from the cache.
+func (c *ShardedCache[K, V]) Delete(key K) {
+	s := c.shardFor(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if e, ok := s.data[key]; ok {
+		c.remove(s, key, e)
+	}
+}
+
+// Keys returns the keys of all unexpired entries, in no particular order.
+func (c *ShardedCache[K, V]) Keys() []K {
+	now := time.Now()
+	var keys []K
+	for _, s := range c.shards {
+		s.mutex.RLock()
+		for key, e := range s.data {
+			if !now.After(e.expiration) {
+				keys = append(keys, key)
+			}
+		}
+		s.mutex.RUnlock()
+	}
+	return keys
+}
+
+// Len returns the number of entries held, including expired and stale ones not yet cleaned up.
+func (c *ShardedCache[K, V]) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mutex.RLock()
+		n += len(s.data)
+		s.mutex.RUnlock()
+	}
+	return n
+}
+
+// Get retrieves a value from the cache and marks it as recently used.
+func (c *ShardedCache[K, V]) Get(key K) (V, bool) {
+	item, ok := c.read(key, 0)
+	return item.Value, ok
+}
+
+// Lookup is like Get, but also returns entries past their TTL for as long as
+// WithMaxStale allows, flagged as stale, and reports the value's age.
+func (c *ShardedCache[K, V]) Lookup(key K) (Item[V], bool) {
+	return c.read(key, c.maxStale)
+}
+
+// read returns the entry of key if it is no more than maxStale past its TTL,
+// and counts the lookup.
+func (c *ShardedCache[K, V]) read(key K, maxStale time.Duration) (Item[V], bool) {
+	s := c.shardFor(key)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := time.Now()
+	e, ok := s.data[key]
+	if !ok {
+		c.counts.miss.Add(1)
+		return Item[V]{}, false
+	}
+	stale := now.After(e.expiration)
+	if (stale && maxStale == 0) || now.After(e.expiration.Add(maxStale)) {
+		c.counts.expired.Add(1)
+		return Item[V]{}, false
+	}
+
+	if stale {
+		c.counts.stale.Add(1)
+	} else {
+		c.counts.hit.Add(1)
+	}
+	e.lastUsed.Store(s.clock.Add(1))
+	return Item[V]{Value: e.value, Age: now.Sub(e.updated), Stale: stale}, true
+}
+
+// evict drops the least recently used entries of s until it is within its
+// bounds. The entry just set, keep, is never evicted. The caller must hold
+// the shard's write lock.
+func (c *ShardedCache[K, V]) evict(s *shard[K, V], keep K) {
+	for len(s.data) > 1 && s.overBudget() {
+		var oldestKey K
+		var oldest *shardEntry[V]
+		for key, e := range s.data {
+			if key != keep && (oldest == nil || e.lastUsed.Load() < oldest.lastUsed.Load()) {
+				oldestKey, oldest = key, e
+			}
+		}
+		c.remove(s, oldestKey, oldest)
+		c.evictions.With("cache", c.name, "reason", "capacity").Add(1)
+	}
+}
+
+func (s *shard[K, V]) overBudget() bool {
+	return (s.maxEntries > 0 && len(s.data) > s.maxEntries) || (s.maxBytes > 0 && s.size > s.maxBytes)
+}
+
+// remove drops an entry. The caller must hold the shard's write lock.
+func (c *ShardedCache[K, V]) remove(s *shard[K, V], key K, e *shardEntry[V]) {
+	delete(s.data, key)
+	s.size -= e.size
+	c.entries.With("cache", c.name).Add(-1)
+	c.bytes.With("cache", c.name).Add(-float64(e.size))
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pavankalyan767/exchange-rate-service/snapshot"
+	"github.com/pavankalyan767/exchange-rate-service/store"
+	"github.com/pavankalyan767/exchange-rate-service/types"
+)
+
+const usage = `usage:
+  rate-exchange-service                    run the service
+  rate-exchange-service export [flags]     write a rate snapshot
+  rate-exchange-service import [flags] [file]
+                                           load a rate snapshot
+
+Without -url, export and import work on the store file directly, which must not
+be open by a running service. With -url they go through the admin endpoint of
+a running service.
+`
+
+// runCommand runs a CLI subcommand and returns the process exit code.
+func runCommand(args []string) int {
+	var err error
+	switch args[0] {
+	case "export":
+		err = runExport(args[1:])
+	case "import":
+		err = runImport(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// snapshotFlags are the flags shared by export and import.
+type snapshotFlags struct {
+	format    string
+	storePath string
+	serverURL string
+	token     string
+}
+
+func newSnapshotFlagSet(name string, f *snapshotFlags) *flag.FlagSet {
+	storePath := os.Getenv("STORE_PATH")
+	if storePath == "" {
+		storePath = "data/rates.db"
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&f.format, "format", "", "archive format, json or csv (default: from the file extension, else json)")
+	fs.StringVar(&f.storePath, "store", storePath, "rate store file of a stopped service")
+	fs.StringVar(&f.serverURL, "url", "", "base URL of a running service, e.g. http://localhost:8080")
+	fs.StringVar(&f.token, "token", os.Getenv("ADMIN_TOKEN"), "admin token of the running service")
+	return fs
+}
+
+func runExport(args []string) error {
+	var f snapshotFlags
+	fs := newSnapshotFlagSet("export", &f)
+	output := fs.String("o", "-", "output file, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	format, err := snapshot.ParseFormat(f.format, *output)
+	if err != nil {
+		return err
+	}
+
+	var snap *snapshot.Snapshot
+	if f.serverURL != "" {
+		snap, err = fetchSnapshot(f, format)
+	} else {
+		err = withStore(f.storePath, func(st *store.Store) (err error) {
+			snap, err = snapshot.FromStore(st)
+			return err
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := snapshot.Write(w, format, snap); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "exported %d entries\n", len(snap.Entries))
+	return nil
+}
+
+func runImport(args []string) error {
+	var f snapshotFlags
+	fs := newSnapshotFlagSet("import", &f)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	input := fs.Arg(0)
+	if input == "" {
+		input = "-"
+	}
+	format, err := snapshot.ParseFormat(f.format, input)
+	if err != nil {
+		return err
+	}
+
+	r := io.Reader(os.Stdin)
+	if input != "-" {
+		file, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	snap, err := snapshot.Read(r, format)
+	if err != nil {
+		return err
+	}
+
+	var imported int
+	if f.serverURL != "" {
+		imported, err = pushSnapshot(f, snap)
+	} else {
+		err = withStore(f.storePath, func(st *store.Store) (err error) {
+			imported, err = snapshot.ToStore(st, snap)
+			return err
+		})
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "imported %d entries\n", imported)
+	return nil
+}
+
+// withStore opens the store at path for the duration of fn.
+func withStore(path string, fn func(*store.Store) error) error {
+	st, err := store.Open(path)
+	if err != nil {
+		return fmt.Errorf("%w (is the service still running? use -url instead)", err)
+	}
+	defer st.Close()
+	return fn(st)
+}
+
+// snapshotRequest calls the admin snapshot endpoint of the running service.
+func snapshotRequest(f snapshotFlags, method string, format snapshot.Format, body io.Reader) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	endpoint := strings.TrimSuffix(f.serverURL, "/") + "/admin/snapshot?" + url.Values{"format": {string(format)}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	// Read the whole body before the context is cancelled.
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+func fetchSnapshot(f snapshotFlags, format snapshot.Format) (*snapshot.Snapshot, error) {
+	resp, err := snapshotRequest(f, http.MethodGet, format, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("service answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return snapshot.Read(resp.Body, format)
+}
+
+func pushSnapshot(f snapshotFlags, snap *snapshot.Snapshot) (int, error) {
+	var body bytes.Buffer
+	if err := snapshot.Write(&body, snapshot.FormatJSON, snap); err != nil {
+		return 0, err
+	}
+	resp, err := snapshotRequest(f, http.MethodPost, snapshot.FormatJSON, &body)
+	if err != nil {
+		return 0, err
+	}
+
+	var result types.SnapshotImportResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("service answered %s", resp.Status)
+	}
+	if result.Error != "" {
+		return 0, fmt.Errorf("service rejected the snapshot: %s", result.Error)
+	}
+	return result.Imported, nil
+}
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+)
+
+// ErrCircuitOpen is returned by APIClient.Get while the provider's circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every request through.
+	BreakerClosed BreakerState = iota
+	// BreakerHalfOpen lets a single probe request through to test the upstream.
+	BreakerHalfOpen
+	// BreakerOpen rejects every request until the open timeout has passed.
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker stops calls to an upstream after consecutive failures.
+// After failureThreshold failures in a row it opens and rejects calls for
+// openTimeout; it then half-opens and lets one probe through, closing again on
+// success or reopening on failure.
+type CircuitBreaker struct {
+	name             string
+	failureThreshold int
+	openTimeout      time.Duration
+	stateGauge       metrics.Gauge // labels: provider
+
+	mutex    sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker creates a closed circuit breaker for the named provider.
+// The state gauge, if not nil, is set to the numeric BreakerState on every transition.
+func NewCircuitBreaker(name string, failureThreshold int, openTimeout time.Duration, stateGauge metrics.Gauge) *CircuitBreaker {
+	if stateGauge == nil {
+		stateGauge = discard.NewGauge()
+	}
+	b := &CircuitBreaker{
+		name:             name,
+		failureThreshold: max(failureThreshold, 1),
+		openTimeout:      openTimeout,
+		stateGauge:       stateGauge,
+	}
+	b.stateGauge.With("provider", name).Set(float64(BreakerClosed))
+	return b
+}
+
+// Allow reports whether a call may proceed, returning ErrCircuitOpen if not.
+// A caller that is allowed through must report the result with Success or Failure.
+func (b *CircuitBreaker) Allow() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.currentState() {
+	case BreakerOpen:
+		return ErrCircuitOpen
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Success records a successful call and closes the breaker.
+func (b *CircuitBreaker) Success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	b.setState(BreakerClosed)
+}
+
+// Failure records a failed call, opening the breaker once the threshold is reached
+// or immediately if the failed call was a half-open probe.
+func (b *CircuitBreaker) Failure() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	if b.currentState() == BreakerHalfOpen || b.failures >= b.failureThreshold {
+		b.probing = false
+		b.openedAt = time.Now()
+		b.setState(BreakerOpen)
+	}
+}
+
+// Abandon records a call that ended without a verdict on the upstream, for
+// example because the caller cancelled it. It frees the half-open probe slot
+// without counting a success or a failure.
+func (b *CircuitBreaker) Abandon() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.probing = false
+}
+
+// State returns the current state of the breaker.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.currentState()
+}
+
+// Failures returns the number of consecutive failures recorded.
+func (b *CircuitBreaker) Failures() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.failures
+}
+
+// currentState moves an open breaker to half-open once its timeout has passed.
+// The caller must hold the mutex.
+func (b *CircuitBreaker) currentState() BreakerState {
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.openTimeout {
+		b.setState(BreakerHalfOpen)
+	}
+	return b.state
+}
+
+// setState changes the state and updates the gauge. The caller must hold the mutex.
+func (b *CircuitBreaker) setState(state BreakerState) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	b.stateGauge.With("provider", b.name).Set(float64(state))
+}
@@ -2,61 +2,274 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
+	"strconv"
 	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
 	"github.com/go-kit/log"
 )
 
-// APIClient encapsulates all logic for making HTTP requests to the external API.
-// It holds its own dependencies (baseURL, fiatapikey, and http client).
+// APIClient encapsulates all logic for making HTTP requests to an external API.
+// Each upstream provider owns its own APIClient, so the name identifies the
+// provider in logs and metrics.
 type APIClient struct {
-	fiatURL      string
-	cryptoURL    string
-	fiatapikey   string
-	cryptoapikey string // This can be used for future crypto-related requests.
-	client       *http.Client
-	logger	   log.Logger // Logger for logging requests and responses.
+	name   string
+	client *http.Client
+	logger log.Logger // Logger for logging requests and responses.
+
+	headers http.Header
+	retry   RetryPolicy
+	breaker *CircuitBreaker
+	quota   *Quota
+
+	mode        Mode
+	fixturesDir string
+	attempts    metrics.Counter // labels: provider, status
+	outcomes    metrics.Counter // labels: provider, outcome
+}
+
+// ClientOption configures optional APIClient behaviour.
+type ClientOption func(*APIClient)
+
+// WithRetryPolicy sets how transient failures are retried.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *APIClient) {
+		c.retry = policy
+	}
+}
+
+// WithHeader adds a header to every request, for providers that accept their
+// API key in a header rather than in the URL.
+func WithHeader(key, value string) ClientOption {
+	return func(c *APIClient) {
+		c.headers.Set(key, value)
+	}
+}
+
+// WithCircuitBreaker guards every Get call with the given circuit breaker.
+func WithCircuitBreaker(breaker *CircuitBreaker) ClientOption {
+	return func(c *APIClient) {
+		c.breaker = breaker
+	}
+}
+
+// WithQuota counts every HTTP attempt against the given monthly quota and
+// refuses further requests once it is used up.
+func WithQuota(quota *Quota) ClientOption {
+	return func(c *APIClient) {
+		c.quota = quota
+	}
+}
+
+// WithFixtures sets the record/replay mode. In ModeRecord every successful
+// response is saved to dir; in ModeReplay responses are served from dir and the
+// network, circuit breaker and quota are never touched.
+func WithFixtures(mode Mode, dir string) ClientOption {
+	return func(c *APIClient) {
+		c.mode = mode
+		c.fixturesDir = dir
+	}
 }
 
+// WithMetrics counts every HTTP attempt by status (labels: provider, status) and
+// the final result of every Get call (labels: provider, outcome).
+func WithMetrics(attempts, outcomes metrics.Counter) ClientOption {
+	return func(c *APIClient) {
+		c.attempts = attempts
+		c.outcomes = outcomes
+	}
+}
+
+// requestTimeout bounds every attempt, including those of callers that set no
+// deadline. A longer AttemptTimeout raises it.
+const requestTimeout = 10 * time.Second
+
 // NewAPIClient is a constructor that creates and returns a new APIClient instance.
 // This is where all dependencies are injected.
-func NewAPIClient(fiatURL, cryptoURL, fiatapikey, cryptoapikey string,logger log.Logger) *APIClient {
-	return &APIClient{
-		fiatURL:      fiatURL,
-		cryptoURL:    cryptoURL,
-		fiatapikey:   fiatapikey,
-		cryptoapikey: cryptoapikey,
-		client:       &http.Client{Timeout: 10 * time.Second},
-		logger:			logger,
+func NewAPIClient(name string, logger log.Logger, opts ...ClientOption) *APIClient {
+	c := &APIClient{
+		name:     name,
+		client:   &http.Client{Timeout: requestTimeout},
+		logger:   log.With(logger, "provider", name),
+		headers:  http.Header{},
+		retry:    DefaultRetryPolicy,
+		mode:     ModeLive,
+		attempts: discard.NewCounter(),
+		outcomes: discard.NewCounter(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.client.Timeout = max(requestTimeout, c.retry.AttemptTimeout)
+	return c
+}
+
+// Name returns the name of the provider this client talks to.
+func (c *APIClient) Name() string {
+	return c.name
+}
+
+// Breaker returns the client's circuit breaker, or nil if it has none.
+func (c *APIClient) Breaker() *CircuitBreaker {
+	return c.breaker
+}
+
+// Available reports whether the client would currently send requests, that is
+// whether its circuit breaker, if any, is not open and its quota, if any, is not used up.
+func (c *APIClient) Available() bool {
+	if c.breaker != nil && c.breaker.State() == BreakerOpen {
+		return false
+	}
+	return c.quota == nil || c.quota.Remaining() > 0
+}
+
+// Budget returns the requests left in the client's monthly quota and the time
+// until it resets. ok is false when the client has no quota.
+func (c *APIClient) Budget() (remaining int, resetsIn time.Duration, ok bool) {
+	if c.quota == nil {
+		return 0, 0, false
 	}
+	return c.quota.Remaining(), c.quota.ResetsIn(), true
 }
 
 // Get performs a GET request and returns the response body as a byte slice.
-// It uses the pre-initialized http.Client from the struct.
+// Transient failures are retried according to the client's RetryPolicy, with
+// exponential backoff and jitter, until the attempts are used up or ctx is done.
+// While the circuit breaker is open, Get fails fast with ErrCircuitOpen.
+// Credentials in requestURL never appear in the client's logs or returned errors.
 func (c *APIClient) Get(ctx context.Context, requestURL string) ([]byte, error) {
-	c.logger.Log("Request URL:", requestURL)
+	if c.mode == ModeReplay {
+		body, err := loadFixture(c.fixturesDir, requestURL)
+		if err != nil {
+			c.logger.Log("Error", "failed to replay response", "err", err)
+			c.outcomes.With("provider", c.name, "outcome", "replay_missing").Add(1)
+			return nil, err
+		}
+		c.outcomes.With("provider", c.name, "outcome", "replayed").Add(1)
+		return body, nil
+	}
+
+	if c.breaker != nil {
+		if err := c.breaker.Allow(); err != nil {
+			c.outcomes.With("provider", c.name, "outcome", "circuit_open").Add(1)
+			return nil, err
+		}
+	}
+
+	body, err := c.get(ctx, requestURL)
+	if err == nil && c.mode == ModeRecord {
+		if err := saveFixture(c.fixturesDir, requestURL, body); err != nil {
+			c.logger.Log("Warning", "failed to record response", "err", err)
+		}
+	}
+	if c.breaker != nil {
+		switch {
+		case err == nil:
+			c.breaker.Success()
+		case errors.Is(ctx.Err(), context.Canceled), errors.Is(err, ErrQuotaExhausted):
+			// Neither the caller giving up nor our own budget says anything about the
+			// upstream. A deadline running out, on the other hand, is exactly what the
+			// breaker guards against.
+			c.breaker.Abandon()
+		default:
+			c.breaker.Failure()
+		}
+	}
+	return body, err
+}
+
+// get performs the request with retries.
+func (c *APIClient) get(ctx context.Context, requestURL string) ([]byte, error) {
+	c.logger.Log("Request URL:", SanitizeURL(requestURL))
 
 	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
 	if err != nil {
+		err = RedactError(err)
 		c.logger.Log("Error", "failed to create new request", "err", err)
+		c.outcomes.With("provider", c.name, "outcome", "failure").Add(1)
 		return nil, fmt.Errorf("failed to create new request: %w", err)
 	}
+	for key, values := range c.headers {
+		req.Header[key] = values
+	}
+
+	attempts := max(c.retry.MaxAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		if c.quota != nil {
+			if err := c.quota.Reserve(); errors.Is(err, ErrQuotaExhausted) {
+				c.logger.Log("Error", "monthly request quota exhausted, not calling upstream", "limit", c.quota.Limit())
+				c.outcomes.With("provider", c.name, "outcome", "quota_exhausted").Add(1)
+				return nil, err
+			} else if err != nil {
+				c.logger.Log("Warning", "failed to persist quota usage", "err", err)
+			}
+		}
+
+		body, err := c.attempt(ctx, req)
+		if err == nil {
+			c.outcomes.With("provider", c.name, "outcome", "success").Add(1)
+			return body, nil
+		}
+
+		if !retryable(ctx, err) {
+			c.outcomes.With("provider", c.name, "outcome", "failure").Add(1)
+			return nil, err
+		}
+		if attempt >= attempts {
+			c.outcomes.With("provider", c.name, "outcome", "retries_exhausted").Add(1)
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		delay := c.retry.backoff(attempt, err)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			c.outcomes.With("provider", c.name, "outcome", "retries_exhausted").Add(1)
+			return nil, fmt.Errorf("giving up after %d attempts, retrying in %s would pass the deadline: %w", attempt, delay, err)
+		}
+		c.logger.Log("Warning", "transient upstream failure, retrying", "attempt", attempt, "delay", delay, "err", err)
+		if err := sleep(ctx, delay); err != nil {
+			c.outcomes.With("provider", c.name, "outcome", "canceled").Add(1)
+			return nil, fmt.Errorf("retry canceled: %w", err)
+		}
+	}
+}
+
+// attempt makes a single attempt at req, bounded by the policy's AttemptTimeout.
+func (c *APIClient) attempt(ctx context.Context, req *http.Request) ([]byte, error) {
+	if c.retry.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.retry.AttemptTimeout)
+		defer cancel()
+	}
+	return c.do(req.Clone(ctx))
+}
 
+// do makes a single attempt at the request.
+func (c *APIClient) do(req *http.Request) ([]byte, error) {
 	resp, err := c.client.Do(req)
 	if err != nil {
+		// Transport errors quote the full request URL, key included.
+		err = RedactError(err)
+		c.attempts.With("provider", c.name, "status", "network_error").Add(1)
 		c.logger.Log("Error", "failed to execute request", "err", err)
 		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	
+	c.attempts.With("provider", c.name, "status", strconv.Itoa(resp.StatusCode)).Add(1)
 
 	if resp.StatusCode != http.StatusOK {
+		// Drain the body so the connection can be reused.
+		io.Copy(io.Discard, resp.Body)
 		c.logger.Log("Error", "received non-200 response", "status_code", resp.StatusCode)
-		return nil, fmt.Errorf("failed to fetch exchange rate from API. Status code: %d", resp.StatusCode)
+		return nil, &StatusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -67,26 +280,3 @@ func (c *APIClient) Get(ctx context.Context, requestURL string) ([]byte, error)
 
 	return body, nil
 }
-
-// BuildLiveURL constructs the URL for a live exchange rate request.
-// It is now a method of APIClient and uses the encapsulated baseURL and fiatapikey.
-func (c *APIClient) BuildLiveURL(endpoint, baseCurrency string) string {
-	return fmt.Sprintf("%s%s?access_key=%s&source=%s", c.fiatURL, endpoint, c.fiatapikey, baseCurrency)
-}
-
-// BuildHistoryURL constructs the URL for a historical data request.
-// It is now a method of APIClient and uses the encapsulated baseURL and fiatapikey.
-func (c *APIClient) BuildHistoryURL(startDate, endDate string, currencies map[string]struct{}) string {
-	var currency_slice []string
-	for currency := range currencies {
-		currency_slice = append(currency_slice, currency)
-	}
-	currenciesStr := strings.Join(currency_slice, ",")
-
-	return fmt.Sprintf("%s/timeframe?access_key=%s&currencies=%s&start_date=%s&end_date=%s",
-		c.fiatURL, c.fiatapikey, currenciesStr, startDate, endDate)
-}
-
-func (c *APIClient) BuildCryptoUrl(endpoint string) string {
-	return fmt.Sprintf("%s%s?access_key=%s", c.cryptoURL, endpoint, c.cryptoapikey)
-}
@@ -0,0 +1,259 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/pavankalyan767/exchange-rate-service/client"
+	"github.com/pavankalyan767/exchange-rate-service/fakeupstream"
+)
+
+var fastRetries = client.RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     10 * time.Millisecond,
+}
+
+func newTestClient(opts ...client.ClientOption) *client.APIClient {
+	logger := log.NewLogfmtLogger(os.Stderr)
+	return client.NewAPIClient("fake", logger, append([]client.ClientOption{client.WithRetryPolicy(fastRetries)}, opts...)...)
+}
+
+func TestGet_RetriesTransientFailures(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+	upstream.Enqueue(fakeupstream.FiatLive, fakeupstream.ServerError, fakeupstream.TooManyRequests)
+
+	body, err := newTestClient().Get(context.Background(), upstream.FiatURL()+"live")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(body) == 0 {
+		t.Errorf("expected a response body")
+	}
+	if got := upstream.Requests(fakeupstream.FiatLive); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestGet_RetriesAttemptsThatTimeOut(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+	upstream.Enqueue(fakeupstream.FiatLive, fakeupstream.Latency(time.Second))
+
+	policy := fastRetries
+	policy.AttemptTimeout = 50 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if _, err := newTestClient(client.WithRetryPolicy(policy)).Get(ctx, upstream.FiatURL()+"live"); err != nil {
+		t.Fatalf("expected the second attempt to succeed, got %v", err)
+	}
+	if got := upstream.Requests(fakeupstream.FiatLive); got != 2 {
+		t.Errorf("expected 2 attempts, got %d", got)
+	}
+}
+
+func TestGet_StopsRetryingWhenCallerGivesUp(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+	upstream.Enqueue(fakeupstream.FiatLive, fakeupstream.Latency(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := newTestClient().Get(ctx, upstream.FiatURL()+"live"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the caller's deadline error, got %v", err)
+	}
+	if got := upstream.Requests(fakeupstream.FiatLive); got != 1 {
+		t.Errorf("expected 1 attempt, got %d", got)
+	}
+}
+
+func TestGet_BacksOffExponentially(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+	upstream.Enqueue(fakeupstream.FiatLive, fakeupstream.ServerError, fakeupstream.ServerError, fakeupstream.ServerError)
+
+	policy := client.RetryPolicy{MaxAttempts: 4, InitialBackoff: 20 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	start := time.Now()
+	if _, err := newTestClient(client.WithRetryPolicy(policy)).Get(context.Background(), upstream.FiatURL()+"live"); err != nil {
+		t.Fatalf("expected the fourth attempt to succeed, got %v", err)
+	}
+	// 20ms, then 40ms, then 80ms capped at 50ms.
+	if elapsed := time.Since(start); elapsed < 110*time.Millisecond || elapsed > time.Second {
+		t.Errorf("expected about 110ms of backoff, got %s", elapsed)
+	}
+}
+
+func TestGet_HonoursRetryAfter(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+	upstream.Enqueue(fakeupstream.FiatLive, fakeupstream.TooManyRequests)
+
+	policy := client.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Second}
+	start := time.Now()
+	if _, err := newTestClient(client.WithRetryPolicy(policy)).Get(context.Background(), upstream.FiatURL()+"live"); err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("expected the retry to wait for Retry-After (1s), got %s", elapsed)
+	}
+
+	// MaxBackoff caps the delay the upstream asks for.
+	upstream.Enqueue(fakeupstream.FiatLive, fakeupstream.TooManyRequests)
+	policy.MaxBackoff = 10 * time.Millisecond
+	start = time.Now()
+	if _, err := newTestClient(client.WithRetryPolicy(policy)).Get(context.Background(), upstream.FiatURL()+"live"); err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("expected Retry-After to be capped at 10ms, got %s", elapsed)
+	}
+}
+
+func TestRetryPolicy_AttemptTimeoutLeavesRoomForBackoff(t *testing.T) {
+	// 10s less the 500ms and 1s delays, shared by three attempts.
+	if timeout := client.DefaultRetryPolicy.AttemptTimeoutWithin(10 * time.Second); timeout != 2833333333*time.Nanosecond {
+		t.Errorf("expected attempts of 2.83s, got %s", timeout)
+	}
+	if timeout := client.DefaultRetryPolicy.AttemptTimeoutWithin(time.Second); timeout != 0 {
+		t.Errorf("expected no attempt timeout when the delays use up the budget, got %s", timeout)
+	}
+}
+
+func TestGet_GivesUpWhenRetryAfterPassesDeadline(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+	upstream.Enqueue(fakeupstream.FiatLive, fakeupstream.TooManyRequests)
+
+	policy := client.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Second}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := newTestClient(client.WithRetryPolicy(policy)).Get(ctx, upstream.FiatURL()+"live")
+	var statusErr *client.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 429 {
+		t.Errorf("expected the 429 to be returned, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Errorf("expected to give up without waiting for Retry-After, took %s", elapsed)
+	}
+	if got := upstream.Requests(fakeupstream.FiatLive); got != 1 {
+		t.Errorf("expected 1 attempt, got %d", got)
+	}
+}
+
+func TestGet_CircuitBreakerOpensAfterFailures(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+	for range 6 {
+		upstream.Enqueue(fakeupstream.FiatLive, fakeupstream.ServerError)
+	}
+
+	breaker := client.NewCircuitBreaker("fake", 2, time.Minute, nil)
+	c := newTestClient(client.WithRetryPolicy(client.RetryPolicy{MaxAttempts: 1}), client.WithCircuitBreaker(breaker))
+
+	for range 2 {
+		if _, err := c.Get(context.Background(), upstream.FiatURL()+"live"); err == nil {
+			t.Fatalf("expected upstream error")
+		}
+	}
+	if _, err := c.Get(context.Background(), upstream.FiatURL()+"live"); !errors.Is(err, client.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if got := upstream.Requests(fakeupstream.FiatLive); got != 2 {
+		t.Errorf("expected the open breaker to stop requests after 2, got %d", got)
+	}
+	if c.Available() {
+		t.Errorf("expected client to report itself unavailable")
+	}
+}
+
+func TestGet_StopsWhenQuotaExhausted(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+
+	quota, err := client.NewQuota("fake", 1, t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("failed to create quota: %v", err)
+	}
+	c := newTestClient(client.WithQuota(quota))
+
+	if _, err := c.Get(context.Background(), upstream.FiatURL()+"live"); err != nil {
+		t.Fatalf("expected first request to succeed, got %v", err)
+	}
+	if _, err := c.Get(context.Background(), upstream.FiatURL()+"live"); !errors.Is(err, client.ErrQuotaExhausted) {
+		t.Fatalf("expected ErrQuotaExhausted, got %v", err)
+	}
+	if got := upstream.Requests(fakeupstream.FiatLive); got != 1 {
+		t.Errorf("expected 1 request to reach the upstream, got %d", got)
+	}
+}
+
+func TestGet_ReplaysRecordedResponses(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	dir := t.TempDir()
+
+	recorded, err := newTestClient(client.WithFixtures(client.ModeRecord, dir)).Get(context.Background(), upstream.FiatURL()+"live?access_key=secret")
+	if err != nil {
+		t.Fatalf("expected recording to succeed, got %v", err)
+	}
+	srv.Close()
+
+	replayed, err := newTestClient(client.WithFixtures(client.ModeReplay, dir)).Get(context.Background(), upstream.FiatURL()+"live?access_key=other")
+	if err != nil {
+		t.Fatalf("expected replay to succeed, got %v", err)
+	}
+	if string(replayed) != string(recorded) {
+		t.Errorf("expected replayed body %q, got %q", recorded, replayed)
+	}
+}
+
+func TestGet_ReplaysEachRecordedHistoryRange(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	dir := t.TempDir()
+	upstream.SetHistory("2024-01-01", map[string]float64{"USDINR": 83})
+	upstream.SetHistory("2024-01-02", map[string]float64{"USDINR": 84})
+
+	// History is fetched in chunks; each range is recorded on its own.
+	ranges := []string{"start_date=2024-01-01&end_date=2024-01-01", "start_date=2024-01-02&end_date=2024-01-02"}
+	recorded := map[string]string{}
+	for _, dates := range ranges {
+		body, err := newTestClient(client.WithFixtures(client.ModeRecord, dir)).Get(context.Background(), upstream.FiatURL()+"timeframe?access_key=secret&"+dates)
+		if err != nil {
+			t.Fatalf("expected recording %s to succeed, got %v", dates, err)
+		}
+		recorded[dates] = string(body)
+	}
+	srv.Close()
+	if recorded[ranges[0]] == recorded[ranges[1]] {
+		t.Fatal("expected the two ranges to answer differently")
+	}
+
+	replay := newTestClient(client.WithFixtures(client.ModeReplay, dir))
+	for _, dates := range ranges {
+		replayed, err := replay.Get(context.Background(), upstream.FiatURL()+"timeframe?access_key=other&"+dates)
+		if err != nil {
+			t.Fatalf("expected replay of %s to succeed, got %v", dates, err)
+		}
+		if string(replayed) != recorded[dates] {
+			t.Errorf("expected replayed body %q for %s, got %q", recorded[dates], dates, replayed)
+		}
+	}
+	if _, err := replay.Get(context.Background(), upstream.FiatURL()+"timeframe?start_date=2024-01-05&end_date=2024-01-06"); !errors.Is(err, client.ErrFixtureNotFound) {
+		t.Errorf("expected an unrecorded range not to replay, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+)
+
+// ErrQuotaExhausted is returned by APIClient.Get once the provider's monthly request budget is used up.
+var ErrQuotaExhausted = errors.New("monthly request quota exhausted")
+
+// quotaPeriodFormat identifies a calendar month; quotas reset when it changes.
+const quotaPeriodFormat = "2006-01"
+
+// Quota tracks the requests made to a provider against a monthly budget.
+// The count is persisted to a JSON file so that restarts do not reset it.
+type Quota struct {
+	name           string
+	limit          int
+	path           string
+	remainingGauge metrics.Gauge // labels: provider
+
+	mutex  sync.Mutex
+	period string
+	used   int
+}
+
+type quotaState struct {
+	Period string `json:"period"`
+	Used   int    `json:"used"`
+}
+
+// NewQuota creates a quota of limit requests per calendar month (UTC) for the named
+// provider, persisted under stateDir. Any count saved by a previous run in the
+// current month is loaded. The remaining gauge, if not nil, tracks the requests left.
+func NewQuota(name string, limit int, stateDir string, remainingGauge metrics.Gauge) (*Quota, error) {
+	if remainingGauge == nil {
+		remainingGauge = discard.NewGauge()
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create quota state directory: %w", err)
+	}
+
+	q := &Quota{
+		name:           name,
+		limit:          limit,
+		path:           filepath.Join(stateDir, name+".quota.json"),
+		remainingGauge: remainingGauge,
+		period:         time.Now().UTC().Format(quotaPeriodFormat),
+	}
+
+	data, err := os.ReadFile(q.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read quota state: %w", err)
+	default:
+		var state quotaState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("failed to parse quota state %s: %w", q.path, err)
+		}
+		if state.Period == q.period {
+			q.used = state.Used
+		}
+	}
+
+	q.remainingGauge.With("provider", name).Set(float64(q.remaining()))
+	return q, nil
+}
+
+// Reserve takes one request from the budget, returning ErrQuotaExhausted if none is left.
+// Any other error means the request was counted but the count could not be persisted.
+func (q *Quota) Reserve() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.rollover()
+	if q.used >= q.limit {
+		return ErrQuotaExhausted
+	}
+	q.used++
+	q.remainingGauge.With("provider", q.name).Set(float64(q.remaining()))
+	return q.save()
+}
+
+// Remaining returns the number of requests left in the current month.
+func (q *Quota) Remaining() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.rollover()
+	return q.remaining()
+}
+
+// Limit returns the monthly request budget.
+func (q *Quota) Limit() int {
+	return q.limit
+}
+
+// ResetsIn returns the time left until the budget is renewed.
+func (q *Quota) ResetsIn() time.Duration {
+	now := time.Now().UTC()
+	nextMonth := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	return nextMonth.Sub(now)
+}
+
+func (q *Quota) remaining() int {
+	return max(q.limit-q.used, 0)
+}
+
+// rollover resets the count when a new month has started. The caller must hold the mutex.
+func (q *Quota) rollover() {
+	period := time.Now().UTC().Format(quotaPeriodFormat)
+	if period != q.period {
+		q.period = period
+		q.used = 0
+		q.remainingGauge.With("provider", q.name).Set(float64(q.remaining()))
+	}
+}
+
+// save writes the current count to disk, replacing the previous file atomically.
+// The caller must hold the mutex.
+func (q *Quota) save() error {
+	data, err := json.Marshal(quotaState{Period: q.period, Used: q.used})
+	if err != nil {
+		return err
+	}
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save quota state: %w", err)
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		return fmt.Errorf("failed to save quota state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// sensitiveParams are the query parameters that carry credentials in provider URLs.
+var sensitiveParams = map[string]struct{}{
+	"access_key": {},
+	"api_key":    {},
+	"apikey":     {},
+	"key":        {},
+	"token":      {},
+}
+
+// sensitivePattern matches credentials embedded in free text such as error messages.
+var sensitivePattern = regexp.MustCompile(`(?i)\b(access_key|api_key|apikey|key|token)=[^&\s"']*`)
+
+const redacted = "REDACTED"
+
+// SanitizeURL returns rawURL with credentials in its query string and user info replaced,
+// so that it can safely be logged or returned in an error.
+func SanitizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return RedactString(rawURL)
+	}
+
+	if u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+	}
+
+	query := u.Query()
+	changed := false
+	for param := range query {
+		if _, ok := sensitiveParams[strings.ToLower(param)]; ok {
+			query.Set(param, redacted)
+			changed = true
+		}
+	}
+	if changed {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// RedactString replaces credentials found in key=value form anywhere in s.
+func RedactString(s string) string {
+	return sensitivePattern.ReplaceAllString(s, "${1}="+redacted)
+}
+
+// RedactError returns err with credentials removed from its message. The original
+// error stays reachable through errors.Is and errors.As.
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	clean := RedactString(msg)
+	if clean == msg {
+		return err
+	}
+	return &redactedError{msg: clean, err: err}
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
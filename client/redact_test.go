@@ -0,0 +1,32 @@
+package client_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pavankalyan767/exchange-rate-service/client"
+)
+
+func TestSanitizeURL_RedactsAccessKey(t *testing.T) {
+	sanitized := client.SanitizeURL("https://api.example.com/live?access_key=secret123&source=USD")
+
+	if strings.Contains(sanitized, "secret123") {
+		t.Fatalf("expected access key to be redacted, got %s", sanitized)
+	}
+	if !strings.Contains(sanitized, "source=USD") {
+		t.Errorf("expected other parameters to be kept, got %s", sanitized)
+	}
+}
+
+func TestRedactError_KeepsWrappedError(t *testing.T) {
+	cause := errors.New(`Get "https://api.example.com/live?access_key=secret123": dial tcp: connection refused`)
+
+	err := client.RedactError(cause)
+	if strings.Contains(err.Error(), "secret123") {
+		t.Fatalf("expected access key to be redacted, got %s", err)
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("expected redacted error to wrap the original error")
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how APIClient.Get retries transient failures:
+// network errors, 429 Too Many Requests and 5xx responses.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry; it doubles on every further retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts, including delays requested via Retry-After.
+	MaxBackoff time.Duration
+	// Jitter is the fraction (0 to 1) of each delay that is randomised to spread out retries.
+	Jitter float64
+	// AttemptTimeout bounds each attempt, so that an upstream that hangs is retried
+	// within the caller's deadline rather than using all of it; see
+	// AttemptTimeoutWithin. Zero leaves attempts bounded by the caller's context
+	// and the client's request timeout only.
+	AttemptTimeout time.Duration
+}
+
+// AttemptTimeoutWithin returns the attempt timeout that fits every attempt, and
+// the backoff delays between them, within total. It is zero when the delays
+// alone use up total. A Retry-After delay longer than the computed backoff still
+// comes out of total, and Get gives up rather than wait past the caller's
+// deadline.
+func (p RetryPolicy) AttemptTimeoutWithin(total time.Duration) time.Duration {
+	attempts := max(p.MaxAttempts, 1)
+	for retry := 1; retry < attempts; retry++ {
+		total -= min(time.Duration(float64(p.InitialBackoff)*math.Pow(2, float64(retry-1))), p.MaxBackoff)
+	}
+	return max(total/time.Duration(attempts), 0)
+}
+
+// DefaultRetryPolicy makes up to three attempts with a 500ms initial backoff.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	Jitter:         0.2,
+}
+
+// StatusError is returned by APIClient.Get when the upstream answers with a non-200 status.
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return "failed to fetch exchange rate from API. Status code: " + strconv.Itoa(e.StatusCode)
+}
+
+// retryable reports whether an attempt made with ctx that failed with err is
+// worth retrying. Only the caller's ctx being done stops retries: an attempt
+// that ran out of its own AttemptTimeout is retried like any network error.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	return true
+}
+
+// backoff returns the delay before retry number retry (starting at 1).
+// A Retry-After value sent by the upstream takes precedence over the computed delay.
+func (p RetryPolicy) backoff(retry int, err error) time.Duration {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		return min(statusErr.RetryAfter, p.MaxBackoff)
+	}
+
+	delay := float64(p.InitialBackoff) * math.Pow(2, float64(retry-1))
+	delay = min(delay, float64(p.MaxBackoff))
+	if p.Jitter > 0 {
+		delay -= delay * p.Jitter * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// sleep waits for d or until ctx is done, whichever happens first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
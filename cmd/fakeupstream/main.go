@@ -0,0 +1,29 @@
+// Command fakeupstream serves the fake fiat and crypto provider APIs for local
+// development. Point the service at it with:
+//
+//	FIAT_API_URL=http://localhost:8090/fiat/
+//	CRYPTO_API_URL=http://localhost:8090/crypto/
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+
+	"github.com/go-kit/log"
+	"github.com/pavankalyan767/exchange-rate-service/fakeupstream"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "address to listen on")
+	flag.Parse()
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	logger = log.With(logger, "ts", log.DefaultTimestampUTC)
+
+	logger.Log("message", "fake upstream listening", "addr", *addr)
+	if err := http.ListenAndServe(*addr, fakeupstream.New()); err != nil {
+		logger.Log("Error", "server failed to start", "err", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,418 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProviderConfig describes a single upstream rate provider.
+type ProviderConfig struct {
+	Name   string
+	URL    string
+	APIKey string
+	// APIKeyHeader, when set, sends the API key in this request header
+	// instead of the access_key query parameter.
+	APIKeyHeader string
+	// MonthlyQuota is the number of requests the provider allows per month; 0 means unlimited.
+	MonthlyQuota int
+}
+
+// Config holds the service configuration read from the environment.
+type Config struct {
+	// FiatProviders and CryptoProviders are listed in priority order;
+	// the rate fetcher falls through to the next one when a provider fails.
+	FiatProviders   []ProviderConfig
+	CryptoProviders []ProviderConfig
+
+	// ProviderTimeout bounds a single attempt against one provider.
+	ProviderTimeout time.Duration
+
+	// UpstreamMode is "live" (default), "record" or "replay"; see client.Mode.
+	UpstreamMode string
+	// FixturesDir holds the upstream responses saved in record mode and served in replay mode.
+	FixturesDir string
+
+	// Polling schedules. A cron expression, when set, takes precedence over the interval.
+	FiatPollInterval   time.Duration
+	FiatPollCron       string
+	FiatPollJitter     time.Duration
+	CryptoPollInterval time.Duration
+	CryptoPollCron     string
+	CryptoPollJitter   time.Duration
+
+	// HistoryHorizonDays is how many days of historical rates are kept; older days are evicted.
+	HistoryHorizonDays int
+	// HistoryChunkDays caps the days a single historical request spans; longer
+	// ranges are split, and fetched HistoryFetchConcurrency chunks at a time.
+	HistoryChunkDays        int
+	HistoryFetchConcurrency int
+	// HistoryRefreshCron schedules the daily job that stores the previous day's final rates.
+	HistoryRefreshCron string
+	// ReconcileInterval is how often the history window is scanned for missing days to backfill.
+	ReconcileInterval time.Duration
+
+	// LiveRateTTL is how long live rates are served as fresh. Past it they are
+	// served as stale, for up to CacheMaxStale, while a refresh is triggered at
+	// most once per StaleRefreshInterval.
+	LiveRateTTL          time.Duration
+	CacheMaxStale        time.Duration
+	StaleRefreshInterval time.Duration
+
+	// CacheMaxEntries and CacheMaxBytes bound each in-memory rate cache; least
+	// recently used days are evicted beyond them (and reloaded from the store
+	// when needed). 0 means unbounded.
+	CacheMaxEntries int
+	CacheMaxBytes   int
+	// CacheShards, when greater than 1, splits each rate cache into that many
+	// independently locked shards.
+	CacheShards int
+
+	// StorePath is the file of the on-disk rate store that keeps history across restarts.
+	StorePath string
+
+	// FiatCurrencies and CryptoCurrencies are the accepted currency codes; empty
+	// means the built-in defaults. CurrenciesFile, if set, is a JSON list of
+	// currencies used instead, which is read again on every reload.
+	FiatCurrencies   []string
+	CryptoCurrencies []string
+	CurrenciesFile   string
+	// CurrencyDiscovery also accepts the currencies the providers support.
+	CurrencyDiscovery bool
+	// CurrencyReloadInterval is how often the currencies are reloaded; 0 only
+	// reloads them on SIGHUP.
+	CurrencyReloadInterval time.Duration
+
+	// SeedSnapshot, if set, is a snapshot archive imported at startup, before the first fetch.
+	SeedSnapshot string
+	// AdminToken, if set, is required as a bearer token by the admin endpoints.
+	AdminToken string
+
+	// ShutdownTimeout bounds how long in-flight requests may take to finish after a stop signal.
+	ShutdownTimeout time.Duration
+
+	// QuotaStateDir is where per-provider quota usage is persisted across restarts.
+	QuotaStateDir string
+
+	// FetchMode is either "failover" (default) or "consensus".
+	FetchMode string
+	// ConsensusMaxDeviation is the largest relative distance from the median a
+	// quote may have before it is discarded as an outlier in consensus mode.
+	ConsensusMaxDeviation float64
+	// ConsensusQuorum is the minimum number of agreeing providers per currency pair.
+	ConsensusQuorum int
+
+	// Retry settings for transient upstream failures (network errors, 429, 5xx).
+	RetryMaxAttempts    int
+	RetryInitialBackoff time.Duration
+	RetryMaxBackoff     time.Duration
+	RetryJitter         float64
+
+	// Circuit breaker settings, applied to each provider separately.
+	BreakerFailureThreshold int
+	BreakerOpenTimeout      time.Duration
+}
+
+// Load reads the configuration from environment variables.
+//
+// Providers are declared with FIAT_PROVIDERS / CRYPTO_PROVIDERS, a comma-separated
+// list of names in priority order. Each name NAME is configured through
+// FIAT_NAME_API_URL, FIAT_NAME_API_KEY and optionally FIAT_NAME_API_KEY_HEADER and
+// FIAT_NAME_MONTHLY_QUOTA (CRYPTO_NAME_... for crypto). When the list is not set,
+// a single provider is built from FIAT_API_URL, FIAT_API_KEY, FIAT_API_KEY_HEADER
+// and FIAT_MONTHLY_QUOTA (CRYPTO_... for crypto).
+func Load() (*Config, error) {
+	cfg := &Config{}
+
+	fiatProviders, err := loadProviders("FIAT", "currencylayer")
+	if err != nil {
+		return nil, err
+	}
+	if len(fiatProviders) == 0 {
+		return nil, fmt.Errorf("no fiat provider configured: set FIAT_API_URL and FIAT_API_KEY or FIAT_PROVIDERS")
+	}
+	cfg.FiatProviders = fiatProviders
+
+	cryptoProviders, err := loadProviders("CRYPTO", "coinlayer")
+	if err != nil {
+		return nil, err
+	}
+	cfg.CryptoProviders = cryptoProviders
+
+	cfg.ProviderTimeout, err = durationEnv("PROVIDER_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.FiatPollInterval, err = durationEnv("FIAT_POLL_INTERVAL", time.Hour); err != nil {
+		return nil, err
+	}
+	cfg.FiatPollCron = os.Getenv("FIAT_POLL_CRON")
+	if cfg.FiatPollJitter, err = durationEnv("FIAT_POLL_JITTER", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.CryptoPollInterval, err = durationEnv("CRYPTO_POLL_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+	cfg.CryptoPollCron = os.Getenv("CRYPTO_POLL_CRON")
+	if cfg.CryptoPollJitter, err = durationEnv("CRYPTO_POLL_JITTER", 5*time.Second); err != nil {
+		return nil, err
+	}
+
+	if cfg.HistoryHorizonDays, err = intEnv("HISTORY_HORIZON_DAYS", 90); err != nil {
+		return nil, err
+	}
+	if cfg.HistoryHorizonDays < 1 {
+		return nil, fmt.Errorf("invalid HISTORY_HORIZON_DAYS %d: must be at least 1", cfg.HistoryHorizonDays)
+	}
+	if cfg.HistoryChunkDays, err = intEnv("HISTORY_CHUNK_DAYS", 365); err != nil {
+		return nil, err
+	}
+	if cfg.HistoryChunkDays < 1 {
+		return nil, fmt.Errorf("invalid HISTORY_CHUNK_DAYS %d: must be at least 1", cfg.HistoryChunkDays)
+	}
+	if cfg.HistoryFetchConcurrency, err = intEnv("HISTORY_FETCH_CONCURRENCY", 4); err != nil {
+		return nil, err
+	}
+	if cfg.HistoryFetchConcurrency < 1 {
+		return nil, fmt.Errorf("invalid HISTORY_FETCH_CONCURRENCY %d: must be at least 1", cfg.HistoryFetchConcurrency)
+	}
+	cfg.HistoryRefreshCron = os.Getenv("HISTORY_REFRESH_CRON")
+	if cfg.HistoryRefreshCron == "" {
+		cfg.HistoryRefreshCron = "10 0 * * *"
+	}
+	if cfg.ReconcileInterval, err = durationEnv("RECONCILE_INTERVAL", 15*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.ReconcileInterval <= 0 {
+		return nil, fmt.Errorf("invalid RECONCILE_INTERVAL %s: must be positive", cfg.ReconcileInterval)
+	}
+
+	cfg.UpstreamMode = os.Getenv("UPSTREAM_MODE")
+	cfg.FixturesDir = os.Getenv("UPSTREAM_FIXTURES_DIR")
+	if cfg.FixturesDir == "" {
+		cfg.FixturesDir = "fixtures"
+	}
+
+	if cfg.LiveRateTTL, err = durationEnv("LIVE_RATE_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.LiveRateTTL <= 0 {
+		return nil, fmt.Errorf("invalid LIVE_RATE_TTL %s: must be positive", cfg.LiveRateTTL)
+	}
+	if cfg.CacheMaxStale, err = durationEnv("CACHE_MAX_STALE", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.CacheMaxStale < 0 {
+		return nil, fmt.Errorf("invalid CACHE_MAX_STALE %s: must not be negative", cfg.CacheMaxStale)
+	}
+	if cfg.StaleRefreshInterval, err = durationEnv("STALE_REFRESH_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+
+	if cfg.CacheMaxEntries, err = intEnv("CACHE_MAX_ENTRIES", 0); err != nil {
+		return nil, err
+	}
+	if cfg.CacheMaxBytes, err = intEnv("CACHE_MAX_BYTES", 0); err != nil {
+		return nil, err
+	}
+	if cfg.CacheMaxEntries < 0 || cfg.CacheMaxBytes < 0 {
+		return nil, fmt.Errorf("CACHE_MAX_ENTRIES and CACHE_MAX_BYTES must not be negative")
+	}
+	if cfg.CacheShards, err = intEnv("CACHE_SHARDS", 1); err != nil {
+		return nil, err
+	}
+	if cfg.CacheShards < 1 {
+		return nil, fmt.Errorf("invalid CACHE_SHARDS %d: must be at least 1", cfg.CacheShards)
+	}
+
+	cfg.StorePath = os.Getenv("STORE_PATH")
+	if cfg.StorePath == "" {
+		cfg.StorePath = "data/rates.db"
+	}
+
+	cfg.FiatCurrencies = listEnv("FIAT_CURRENCIES")
+	cfg.CryptoCurrencies = listEnv("CRYPTO_CURRENCIES")
+	cfg.CurrenciesFile = os.Getenv("CURRENCIES_FILE")
+	if cfg.CurrencyDiscovery, err = boolEnv("CURRENCY_DISCOVERY", false); err != nil {
+		return nil, err
+	}
+	if cfg.CurrencyReloadInterval, err = durationEnv("CURRENCY_RELOAD_INTERVAL", 0); err != nil {
+		return nil, err
+	}
+	if cfg.CurrencyReloadInterval < 0 {
+		return nil, fmt.Errorf("invalid CURRENCY_RELOAD_INTERVAL %s: must not be negative", cfg.CurrencyReloadInterval)
+	}
+
+	cfg.SeedSnapshot = os.Getenv("SEED_SNAPSHOT")
+	cfg.AdminToken = os.Getenv("ADMIN_TOKEN")
+
+	if cfg.ShutdownTimeout, err = durationEnv("SHUTDOWN_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		return nil, fmt.Errorf("invalid SHUTDOWN_TIMEOUT %s: must be positive", cfg.ShutdownTimeout)
+	}
+
+	cfg.QuotaStateDir = os.Getenv("QUOTA_STATE_DIR")
+	if cfg.QuotaStateDir == "" {
+		cfg.QuotaStateDir = "data"
+	}
+
+	cfg.FetchMode = os.Getenv("FETCH_MODE")
+	switch cfg.FetchMode {
+	case "":
+		cfg.FetchMode = "failover"
+	case "failover", "consensus":
+	default:
+		return nil, fmt.Errorf("invalid FETCH_MODE %q: must be failover or consensus", cfg.FetchMode)
+	}
+
+	cfg.ConsensusMaxDeviation, err = floatEnv("CONSENSUS_MAX_DEVIATION", 0.01)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ConsensusQuorum, err = intEnv("CONSENSUS_QUORUM", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.RetryMaxAttempts, err = intEnv("RETRY_MAX_ATTEMPTS", 3); err != nil {
+		return nil, err
+	}
+	if cfg.RetryInitialBackoff, err = durationEnv("RETRY_INITIAL_BACKOFF", 500*time.Millisecond); err != nil {
+		return nil, err
+	}
+	if cfg.RetryMaxBackoff, err = durationEnv("RETRY_MAX_BACKOFF", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.RetryJitter, err = floatEnv("RETRY_JITTER", 0.2); err != nil {
+		return nil, err
+	}
+	if cfg.RetryJitter < 0 || cfg.RetryJitter > 1 {
+		return nil, fmt.Errorf("invalid RETRY_JITTER %v: must be between 0 and 1", cfg.RetryJitter)
+	}
+
+	if cfg.BreakerFailureThreshold, err = intEnv("BREAKER_FAILURE_THRESHOLD", 5); err != nil {
+		return nil, err
+	}
+	if cfg.BreakerOpenTimeout, err = durationEnv("BREAKER_OPEN_TIMEOUT", time.Minute); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadProviders reads the provider list for the given prefix (FIAT or CRYPTO).
+// A provider without a URL is an error; a missing default provider is not.
+func loadProviders(prefix, defaultName string) ([]ProviderConfig, error) {
+	names := os.Getenv(prefix + "_PROVIDERS")
+	if names == "" {
+		url := os.Getenv(prefix + "_API_URL")
+		if url == "" {
+			return nil, nil
+		}
+		quota, err := intEnv(prefix+"_MONTHLY_QUOTA", 0)
+		if err != nil {
+			return nil, err
+		}
+		return []ProviderConfig{{
+			Name:         defaultName,
+			URL:          url,
+			APIKey:       os.Getenv(prefix + "_API_KEY"),
+			APIKeyHeader: os.Getenv(prefix + "_API_KEY_HEADER"),
+			MonthlyQuota: quota,
+		}}, nil
+	}
+
+	var providers []ProviderConfig
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		envName := prefix + "_" + strings.ToUpper(name)
+		url := os.Getenv(envName + "_API_URL")
+		if url == "" {
+			return nil, fmt.Errorf("%s_API_URL is not set for provider %q", envName, name)
+		}
+		quota, err := intEnv(envName+"_MONTHLY_QUOTA", 0)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, ProviderConfig{
+			Name:         name,
+			URL:          url,
+			APIKey:       os.Getenv(envName + "_API_KEY"),
+			APIKeyHeader: os.Getenv(envName + "_API_KEY_HEADER"),
+			MonthlyQuota: quota,
+		})
+	}
+	return providers, nil
+}
+
+// durationEnv parses a time.Duration from the environment, falling back to def when unset.
+func durationEnv(key string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// floatEnv parses a float64 from the environment, falling back to def when unset.
+func floatEnv(key string, def float64) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return f, nil
+}
+
+// intEnv parses an int from the environment, falling back to def when unset.
+func intEnv(key string, def int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return i, nil
+}
+
+// boolEnv parses a bool from the environment, falling back to def when unset.
+func boolEnv(key string, def bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return b, nil
+}
+
+// listEnv splits a comma-separated list from the environment, upper-casing and
+// trimming its items. It returns nil when unset.
+func listEnv(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.ToUpper(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
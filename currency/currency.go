@@ -0,0 +1,183 @@
+// Package currency keeps the registry of the currencies the service accepts,
+// with their ISO 4217 metadata. The registry is loaded from configuration and,
+// optionally, from the currencies the providers support, and can be reloaded
+// while the service runs; see Loader.
+package currency
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Kind tells fiat currencies, quoted against the base currency, from crypto
+// currencies, quoted in it.
+type Kind string
+
+const (
+	Fiat   Kind = "fiat"
+	Crypto Kind = "crypto"
+)
+
+// defaultCryptoMinorUnits is the number of decimals of a cryptocurrency with no
+// known metadata.
+const defaultCryptoMinorUnits = 8
+
+// Currency describes one accepted currency.
+type Currency struct {
+	Code string `json:"code"`
+	Kind Kind   `json:"kind"`
+	Name string `json:"name,omitempty"`
+	// Numeric is the ISO 4217 numeric code; 0 for currencies outside ISO 4217.
+	Numeric int `json:"numeric,omitempty"`
+	// MinorUnits is the number of decimals of the currency's smallest unit.
+	MinorUnits int `json:"minor_units"`
+}
+
+// ISO4217 returns the fiat currency with the given ISO 4217 code; ok is false
+// if the code is not an active ISO 4217 currency.
+func ISO4217(code string) (c Currency, ok bool) {
+	entry, ok := iso4217[code]
+	if !ok {
+		return Currency{}, false
+	}
+	return Currency{Code: code, Kind: Fiat, Name: entry.name, Numeric: entry.numeric, MinorUnits: entry.minorUnits}, true
+}
+
+// Cryptocurrency returns the crypto currency with the given code. Well-known
+// coins get their usual name and minor units; others are named name, or code
+// when name is empty, with 8 minor units.
+func Cryptocurrency(code, name string) Currency {
+	if entry, ok := knownCrypto[code]; ok {
+		return Currency{Code: code, Kind: Crypto, Name: entry.name, MinorUnits: entry.minorUnits}
+	}
+	if name == "" {
+		name = code
+	}
+	return Currency{Code: code, Kind: Crypto, Name: name, MinorUnits: defaultCryptoMinorUnits}
+}
+
+// FromCodes returns the currencies of the given fiat and crypto codes with their
+// metadata. Every fiat code must be an ISO 4217 code.
+func FromCodes(fiat, crypto []string) ([]Currency, error) {
+	currencies := make([]Currency, 0, len(fiat)+len(crypto))
+	for _, code := range fiat {
+		c, ok := ISO4217(code)
+		if !ok {
+			return nil, fmt.Errorf("unknown fiat currency %q: not an ISO 4217 code", code)
+		}
+		currencies = append(currencies, c)
+	}
+	for _, code := range crypto {
+		currencies = append(currencies, Cryptocurrency(code, ""))
+	}
+	return currencies, nil
+}
+
+// set is an immutable registry content.
+type set struct {
+	byCode map[string]Currency
+	codes  map[Kind]map[string]struct{}
+}
+
+func newSet(currencies []Currency) (*set, error) {
+	s := &set{
+		byCode: make(map[string]Currency, len(currencies)),
+		codes:  map[Kind]map[string]struct{}{Fiat: {}, Crypto: {}},
+	}
+	for _, c := range currencies {
+		if c.Code == "" || c.Code != strings.ToUpper(c.Code) {
+			return nil, fmt.Errorf("invalid currency code %q: must be upper case", c.Code)
+		}
+		codes, ok := s.codes[c.Kind]
+		if !ok {
+			return nil, fmt.Errorf("invalid kind %q for %s: must be fiat or crypto", c.Kind, c.Code)
+		}
+		if existing, ok := s.byCode[c.Code]; ok {
+			return nil, fmt.Errorf("currency %s listed twice (as %s and %s)", c.Code, existing.Kind, c.Kind)
+		}
+		s.byCode[c.Code] = c
+		codes[c.Code] = struct{}{}
+	}
+	return s, nil
+}
+
+// Registry is the set of accepted currencies. Reads never block: Replace swaps
+// the whole set at once. It is safe for concurrent use.
+type Registry struct {
+	current atomic.Pointer[set]
+}
+
+// NewRegistry creates a registry of the given fiat and crypto codes. It is meant
+// for built-in defaults and panics if they are invalid; configured currencies
+// go through Replace.
+func NewRegistry(fiat, crypto []string) *Registry {
+	currencies, err := FromCodes(fiat, crypto)
+	if err != nil {
+		panic(err)
+	}
+	r := &Registry{}
+	if err := r.Replace(currencies); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Replace makes currencies the accepted set. The registry is left unchanged if
+// they are invalid: a code that is empty, not upper case or listed twice, or a
+// kind other than fiat or crypto.
+func (r *Registry) Replace(currencies []Currency) error {
+	s, err := newSet(currencies)
+	if err != nil {
+		return err
+	}
+	r.current.Store(s)
+	return nil
+}
+
+// Lookup returns the currency with the given code; ok is false if it is not accepted.
+func (r *Registry) Lookup(code string) (c Currency, ok bool) {
+	c, ok = r.current.Load().byCode[code]
+	return c, ok
+}
+
+// IsFiat reports whether code is an accepted fiat currency.
+func (r *Registry) IsFiat(code string) bool {
+	c, ok := r.Lookup(code)
+	return ok && c.Kind == Fiat
+}
+
+// IsCrypto reports whether code is an accepted crypto currency.
+func (r *Registry) IsCrypto(code string) bool {
+	c, ok := r.Lookup(code)
+	return ok && c.Kind == Crypto
+}
+
+// IsAllowed reports whether code is an accepted currency of either kind.
+func (r *Registry) IsAllowed(code string) bool {
+	_, ok := r.Lookup(code)
+	return ok
+}
+
+// Codes returns the set of accepted codes of kind. The set is shared and must
+// not be modified; it is not affected by later calls to Replace.
+func (r *Registry) Codes(kind Kind) map[string]struct{} {
+	return r.current.Load().codes[kind]
+}
+
+// All returns the accepted currencies, fiat first, each kind ordered by code.
+func (r *Registry) All() []Currency {
+	byCode := r.current.Load().byCode
+	currencies := make([]Currency, 0, len(byCode))
+	for _, c := range byCode {
+		currencies = append(currencies, c)
+	}
+	slices.SortFunc(currencies, func(a, b Currency) int {
+		if a.Kind != b.Kind {
+			return strings.Compare(string(b.Kind), string(a.Kind)) // "fiat" before "crypto"
+		}
+		return strings.Compare(a.Code, b.Code)
+	})
+	return currencies
+}
@@ -0,0 +1,70 @@
+package currency_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/pavankalyan767/exchange-rate-service/currency"
+)
+
+// stubDiscoverer is a Discoverer returning a fixed currency list.
+type stubDiscoverer map[string]string
+
+func (d stubDiscoverer) SupportedCurrencies(ctx context.Context) (map[string]string, error) {
+	return d, nil
+}
+
+func TestLoader_ReloadsFileAndDiscoveredCurrencies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "currencies.json")
+	writeFile := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("expected no error writing %s, got %v", path, err)
+		}
+	}
+	writeFile(`[{"code": "USD", "kind": "fiat"}, {"code": "INR", "kind": "fiat"}, {"code": "SOL", "kind": "crypto"}]`)
+
+	registry := currency.NewRegistry([]string{"USD"}, nil)
+	loader := currency.NewLoader(registry, log.NewNopLogger(),
+		currency.WithFile(path),
+		currency.WithRequired("USD"),
+		// Only ISO 4217 codes are accepted as discovered fiat currencies.
+		currency.WithDiscovery(currency.Fiat, stubDiscoverer{"CHF": "Swiss Franc", "BTC": "Bitcoin", "XAU": "Gold"}),
+	)
+
+	changed, err := loader.Reload(context.Background())
+	if err != nil || !changed {
+		t.Fatalf("expected the registry to change, got changed %v, err %v", changed, err)
+	}
+	if inr, ok := registry.Lookup("INR"); !ok || inr.Numeric != 356 || inr.MinorUnits != 2 || inr.Name != "Indian Rupee" {
+		t.Errorf("expected INR with its ISO 4217 metadata, got %+v (found %v)", inr, ok)
+	}
+	if sol, ok := registry.Lookup("SOL"); !ok || !registry.IsCrypto("SOL") || sol.MinorUnits != 9 {
+		t.Errorf("expected SOL as a crypto currency with 9 minor units, got %+v (found %v)", sol, ok)
+	}
+	if !registry.IsFiat("CHF") || registry.IsAllowed("BTC") || registry.IsAllowed("XAU") {
+		t.Errorf("expected only CHF to be discovered, got %v", registry.All())
+	}
+
+	writeFile(`[{"code": "USD", "kind": "fiat"}, {"code": "SOL", "kind": "crypto"}]`)
+	if changed, err := loader.Reload(context.Background()); err != nil || !changed {
+		t.Fatalf("expected the registry to change, got changed %v, err %v", changed, err)
+	}
+	if registry.IsAllowed("INR") {
+		t.Error("expected INR to be removed on reload")
+	}
+	if changed, _ := loader.Reload(context.Background()); changed {
+		t.Error("expected an unchanged file to leave the registry unchanged")
+	}
+
+	// An invalid configuration keeps the currencies loaded before.
+	writeFile(`[{"code": "EUR", "kind": "fiat"}]`)
+	if _, err := loader.Reload(context.Background()); err == nil {
+		t.Error("expected an error without the required USD")
+	}
+	if !registry.IsFiat("USD") || !registry.IsCrypto("SOL") {
+		t.Errorf("expected the previous currencies to be kept, got %v", registry.All())
+	}
+}
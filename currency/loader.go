@@ -0,0 +1,200 @@
+package currency
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/go-kit/log"
+)
+
+// Discoverer lists the currencies an upstream supports, mapped to their display
+// names. It is implemented by provider.RateProvider.
+type Discoverer interface {
+	SupportedCurrencies(ctx context.Context) (map[string]string, error)
+}
+
+// Loader fills a Registry from configuration: the currency codes it was given,
+// or a JSON file that replaces them and is read again on every Reload, plus,
+// when discovery is enabled, the currencies the providers support.
+type Loader struct {
+	registry *Registry
+	logger   log.Logger
+
+	fiat, crypto []string
+	file         string
+	discoverers  map[Kind][]Discoverer
+	required     []string
+}
+
+// LoaderOption configures optional Loader behaviour.
+type LoaderOption func(*Loader)
+
+// WithCodes accepts the given fiat and crypto codes.
+func WithCodes(fiat, crypto []string) LoaderOption {
+	return func(l *Loader) {
+		l.fiat = fiat
+		l.crypto = crypto
+	}
+}
+
+// WithFile reads the accepted currencies from a JSON array of Currency objects
+// at path instead of the codes given to WithCodes. Only code and kind are
+// required; the other fields, when left out or zero, default to the ISO 4217
+// metadata for fiat codes, which must then be ISO 4217 codes unless a name is
+// given, and to that of Cryptocurrency for crypto codes. An empty path is ignored.
+func WithFile(path string) LoaderOption {
+	return func(l *Loader) {
+		l.file = path
+	}
+}
+
+// WithDiscovery also accepts, on every Reload, the currencies of kind that the
+// given sources support. Discovered fiat codes are only accepted if they are
+// ISO 4217 codes. A source that fails is logged and skipped.
+func WithDiscovery(kind Kind, sources ...Discoverer) LoaderOption {
+	return func(l *Loader) {
+		l.discoverers[kind] = append(l.discoverers[kind], sources...)
+	}
+}
+
+// WithRequired makes Reload fail unless every given code is an accepted fiat
+// currency, such as the base currency all rates are quoted against.
+func WithRequired(codes ...string) LoaderOption {
+	return func(l *Loader) {
+		l.required = append(l.required, codes...)
+	}
+}
+
+// NewLoader creates a Loader that fills registry.
+func NewLoader(registry *Registry, logger log.Logger, opts ...LoaderOption) *Loader {
+	l := &Loader{
+		registry:    registry,
+		logger:      logger,
+		discoverers: map[Kind][]Discoverer{},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Reload rebuilds the accepted currencies and replaces the content of the
+// registry with them. It reports whether the accepted currencies changed. On
+// error the registry keeps its previous content.
+func (l *Loader) Reload(ctx context.Context) (changed bool, err error) {
+	currencies, err := l.configured()
+	if err != nil {
+		return false, err
+	}
+
+	accepted := make(map[string]struct{}, len(currencies))
+	for _, c := range currencies {
+		accepted[c.Code] = struct{}{}
+	}
+	for _, kind := range []Kind{Fiat, Crypto} {
+		for _, c := range l.discover(ctx, kind) {
+			if _, ok := accepted[c.Code]; !ok {
+				accepted[c.Code] = struct{}{}
+				currencies = append(currencies, c)
+			}
+		}
+	}
+
+	for _, code := range l.required {
+		if !slices.ContainsFunc(currencies, func(c Currency) bool { return c.Code == code && c.Kind == Fiat }) {
+			return false, fmt.Errorf("required fiat currency %s is not configured", code)
+		}
+	}
+
+	previous := l.registry.current.Load()
+	if err := l.registry.Replace(currencies); err != nil {
+		return false, err
+	}
+	current := l.registry.current.Load()
+	changed = previous == nil || !maps.Equal(previous.byCode, current.byCode)
+
+	l.logger.Log("message", "currency registry loaded",
+		"fiat", len(current.codes[Fiat]), "crypto", len(current.codes[Crypto]), "changed", changed)
+	return changed, nil
+}
+
+// configured returns the currencies of the file, if one is set, or of the codes.
+func (l *Loader) configured() ([]Currency, error) {
+	if l.file == "" {
+		return FromCodes(l.fiat, l.crypto)
+	}
+
+	data, err := os.ReadFile(l.file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read currencies file: %w", err)
+	}
+	var currencies []Currency
+	if err := json.Unmarshal(data, &currencies); err != nil {
+		return nil, fmt.Errorf("failed to decode currencies file %s: %w", l.file, err)
+	}
+	for i, c := range currencies {
+		if currencies[i], err = withMetadata(c); err != nil {
+			return nil, fmt.Errorf("invalid currencies file %s: %w", l.file, err)
+		}
+	}
+	return currencies, nil
+}
+
+// withMetadata fills in the fields of c that the file left out.
+func withMetadata(c Currency) (Currency, error) {
+	var defaults Currency
+	switch c.Kind {
+	case Fiat:
+		iso, ok := ISO4217(c.Code)
+		if !ok && c.Name == "" {
+			return Currency{}, fmt.Errorf("fiat currency %q is not an ISO 4217 code and has no name", c.Code)
+		}
+		defaults = iso
+	case Crypto:
+		defaults = Cryptocurrency(c.Code, c.Name)
+	default:
+		return c, nil // rejected by Registry.Replace
+	}
+
+	if c.Name == "" {
+		c.Name = defaults.Name
+	}
+	if c.Numeric == 0 {
+		c.Numeric = defaults.Numeric
+	}
+	if c.MinorUnits == 0 {
+		c.MinorUnits = defaults.MinorUnits
+	}
+	return c, nil
+}
+
+// discover returns the currencies of kind supported by the discovery sources,
+// ordered by code.
+func (l *Loader) discover(ctx context.Context, kind Kind) []Currency {
+	found := map[string]Currency{}
+	for _, source := range l.discoverers[kind] {
+		supported, err := source.SupportedCurrencies(ctx)
+		if err != nil {
+			l.logger.Log("Warning", "currency discovery failed", "kind", kind, "err", err)
+			continue
+		}
+		for code, name := range supported {
+			if kind == Crypto {
+				found[code] = Cryptocurrency(code, name)
+			} else if c, ok := ISO4217(code); ok {
+				found[code] = c
+			}
+		}
+	}
+
+	currencies := slices.Collect(maps.Values(found))
+	slices.SortFunc(currencies, func(a, b Currency) int {
+		return strings.Compare(a.Code, b.Code)
+	})
+	return currencies
+}
@@ -0,0 +1,379 @@
+// Package fakeupstream is an in-process fake of the fiat (currencylayer-style)
+// and crypto (coinlayer-style) provider APIs, for tests and offline development.
+//
+// Fiat endpoints are served under /fiat (live, timeframe, list) and crypto
+// endpoints under /crypto (live, timeframe, list), so a provider configured with
+// URL+"/fiat" or URL+"/crypto" talks to the fake as if it were the real API.
+// Responses are generated from the configured rates unless a scripted Response
+// has been queued for the endpoint.
+package fakeupstream
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Endpoint identifies one of the fake's API endpoints.
+type Endpoint string
+
+const (
+	FiatLive        Endpoint = "/fiat/live"
+	FiatTimeframe   Endpoint = "/fiat/timeframe"
+	FiatList        Endpoint = "/fiat/list"
+	CryptoLive      Endpoint = "/crypto/live"
+	CryptoTimeframe Endpoint = "/crypto/timeframe"
+	CryptoList      Endpoint = "/crypto/list"
+)
+
+const dateFormat = "2006-01-02"
+
+// Response is a scripted answer to a single request.
+type Response struct {
+	// Status is the HTTP status code; zero means 200.
+	Status int
+	// Body replaces the generated body when not empty, e.g. for malformed JSON.
+	Body string
+	// Delay is waited before answering, to simulate latency or timeouts.
+	Delay time.Duration
+	// Header is added to the response, e.g. Retry-After.
+	Header http.Header
+}
+
+// Convenient scripted responses.
+var (
+	ServerError     = Response{Status: http.StatusInternalServerError}
+	TooManyRequests = Response{Status: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"1"}}}
+	MalformedJSON   = Response{Body: `{"quotes": {"USDINR": `}
+	APIError        = Response{Body: `{"success": false, "error": {"code": 104, "info": "Your monthly usage limit has been reached."}}`}
+)
+
+// Latency returns a normal response delayed by d.
+func Latency(d time.Duration) Response {
+	return Response{Delay: d}
+}
+
+// Server is the fake upstream. It is an http.Handler; use Start to serve it on a
+// local test server or mount it on any listener.
+type Server struct {
+	// URL is the base URL of the test server, set by Start.
+	URL string
+
+	mutex       sync.Mutex
+	apiKey      string
+	fiatQuotes  map[string]float64
+	history     map[string]map[string]float64
+	cryptoRates map[string]float64
+	cryptoHist  map[string]map[string]float64
+	scripts     map[Endpoint][]Response
+	requests    map[Endpoint]int
+}
+
+// New creates a fake upstream quoting the default fiat and crypto rates.
+func New() *Server {
+	return &Server{
+		fiatQuotes: map[string]float64{
+			"USDUSD": 1,
+			"USDINR": 83.0,
+			"USDEUR": 0.91,
+			"USDJPY": 148.2,
+			"USDGBP": 0.79,
+		},
+		history: map[string]map[string]float64{},
+		cryptoRates: map[string]float64{
+			"BTC":  30000.0,
+			"ETH":  1800.0,
+			"USDT": 1.0,
+		},
+		cryptoHist: map[string]map[string]float64{},
+		scripts:    map[Endpoint][]Response{},
+		requests:   map[Endpoint]int{},
+	}
+}
+
+// Start serves the fake on a local httptest server and sets URL.
+// The caller must Close the returned server.
+func (s *Server) Start() *httptest.Server {
+	srv := httptest.NewServer(s)
+	s.URL = srv.URL
+	return srv
+}
+
+// FiatURL returns the base URL to configure a fiat provider with.
+func (s *Server) FiatURL() string { return s.URL + "/fiat/" }
+
+// CryptoURL returns the base URL to configure a crypto provider with.
+func (s *Server) CryptoURL() string { return s.URL + "/crypto/" }
+
+// RequireKey makes the fake reject requests without this access key, answering
+// with the API's error object the way the real providers do.
+func (s *Server) RequireKey(apiKey string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.apiKey = apiKey
+}
+
+// SetFiatQuotes replaces the live fiat quotes, keyed by pair (e.g. "USDINR").
+// Leaving pairs out simulates partial quotes.
+func (s *Server) SetFiatQuotes(quotes map[string]float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fiatQuotes = maps.Clone(quotes)
+}
+
+// SetHistory sets the fiat quotes returned by the timeframe endpoint for one date.
+// Dates without explicit history are answered with the live quotes.
+func (s *Server) SetHistory(date string, quotes map[string]float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.history[date] = maps.Clone(quotes)
+}
+
+// SkipHistory makes the timeframe endpoint leave date out of its answers.
+func (s *Server) SkipHistory(date string) {
+	s.SetHistory(date, nil)
+}
+
+// SetCryptoRates replaces the crypto prices, keyed by coin (e.g. "BTC").
+func (s *Server) SetCryptoRates(rates map[string]float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cryptoRates = maps.Clone(rates)
+}
+
+// SetCryptoHistory sets the crypto prices returned by the timeframe endpoint for
+// one date. Dates without explicit history are answered with the live prices.
+func (s *Server) SetCryptoHistory(date string, rates map[string]float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cryptoHist[date] = maps.Clone(rates)
+}
+
+// Enqueue scripts the next answers of an endpoint, one per request. Once the
+// queue is drained the endpoint goes back to generated responses.
+func (s *Server) Enqueue(endpoint Endpoint, responses ...Response) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.scripts[endpoint] = append(s.scripts[endpoint], responses...)
+}
+
+// Requests returns the number of requests an endpoint has received.
+func (s *Server) Requests(endpoint Endpoint) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests[endpoint]
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint := Endpoint(r.URL.Path)
+
+	s.mutex.Lock()
+	s.requests[endpoint]++
+	var script *Response
+	if queue := s.scripts[endpoint]; len(queue) > 0 {
+		script = &queue[0]
+		s.scripts[endpoint] = queue[1:]
+	}
+	apiKey := s.apiKey
+	s.mutex.Unlock()
+
+	if script != nil {
+		if script.Delay > 0 {
+			select {
+			case <-time.After(script.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		for key, values := range script.Header {
+			w.Header()[key] = values
+		}
+		if script.Status != 0 && script.Status != http.StatusOK {
+			w.WriteHeader(script.Status)
+			w.Write([]byte(script.Body))
+			return
+		}
+		if script.Body != "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(script.Body))
+			return
+		}
+	}
+
+	if apiKey != "" && r.URL.Query().Get("access_key") != apiKey && r.Header.Get("apikey") != apiKey {
+		writeJSON(w, map[string]any{
+			"success": false,
+			"error":   map[string]any{"code": 101, "info": "You have not supplied a valid API Access Key."},
+		})
+		return
+	}
+
+	switch endpoint {
+	case FiatLive:
+		s.serveFiatLive(w, r)
+	case FiatTimeframe:
+		s.serveFiatTimeframe(w, r)
+	case FiatList:
+		writeJSON(w, map[string]any{"success": true, "currencies": fiatNames})
+	case CryptoLive:
+		s.serveCryptoLive(w, r)
+	case CryptoTimeframe:
+		s.serveCryptoTimeframe(w, r)
+	case CryptoList:
+		s.serveCryptoList(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveFiatLive(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	quotes := filterPairs(s.fiatQuotes, r.URL.Query().Get("currencies"), "USD")
+	s.mutex.Unlock()
+
+	writeJSON(w, map[string]any{
+		"success":   true,
+		"source":    "USD",
+		"timestamp": time.Now().Unix(),
+		"quotes":    quotes,
+	})
+}
+
+func (s *Server) serveFiatTimeframe(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	start, err := time.Parse(dateFormat, query.Get("start_date"))
+	if err != nil {
+		http.Error(w, "invalid start_date", http.StatusBadRequest)
+		return
+	}
+	end, err := time.Parse(dateFormat, query.Get("end_date"))
+	if err != nil {
+		http.Error(w, "invalid end_date", http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.Lock()
+	quotes := map[string]map[string]float64{}
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		date := d.Format(dateFormat)
+		day, ok := s.history[date]
+		if !ok {
+			day = s.fiatQuotes
+		}
+		if day == nil {
+			continue
+		}
+		quotes[date] = filterPairs(day, query.Get("currencies"), "USD")
+	}
+	s.mutex.Unlock()
+
+	writeJSON(w, map[string]any{
+		"success":    true,
+		"timeframe":  true,
+		"source":     "USD",
+		"start_date": query.Get("start_date"),
+		"end_date":   query.Get("end_date"),
+		"quotes":     quotes,
+	})
+}
+
+func (s *Server) serveCryptoLive(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	rates := filterPairs(s.cryptoRates, r.URL.Query().Get("symbols"), "")
+	s.mutex.Unlock()
+
+	writeJSON(w, map[string]any{
+		"success":   true,
+		"target":    "USD",
+		"timestamp": time.Now().Unix(),
+		"rates":     rates,
+	})
+}
+
+func (s *Server) serveCryptoTimeframe(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	start, err := time.Parse(dateFormat, query.Get("start_date"))
+	if err != nil {
+		http.Error(w, "invalid start_date", http.StatusBadRequest)
+		return
+	}
+	end, err := time.Parse(dateFormat, query.Get("end_date"))
+	if err != nil {
+		http.Error(w, "invalid end_date", http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.Lock()
+	rates := map[string]map[string]float64{}
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		date := d.Format(dateFormat)
+		day, ok := s.cryptoHist[date]
+		if !ok {
+			day = s.cryptoRates
+		}
+		if day == nil {
+			continue
+		}
+		rates[date] = filterPairs(day, query.Get("symbols"), "")
+	}
+	s.mutex.Unlock()
+
+	writeJSON(w, map[string]any{
+		"success":    true,
+		"timeframe":  true,
+		"target":     "USD",
+		"start_date": query.Get("start_date"),
+		"end_date":   query.Get("end_date"),
+		"rates":      rates,
+	})
+}
+
+func (s *Server) serveCryptoList(w http.ResponseWriter) {
+	s.mutex.Lock()
+	crypto := map[string]any{}
+	for coin := range s.cryptoRates {
+		crypto[coin] = map[string]string{"symbol": coin, "name": coin, "name_full": coin}
+	}
+	s.mutex.Unlock()
+
+	writeJSON(w, map[string]any{"success": true, "crypto": crypto})
+}
+
+// filterPairs keeps the entries of rates whose currency, once prefix is stripped
+// from the key, is listed in the comma-separated filter. An empty filter keeps
+// everything.
+func filterPairs(rates map[string]float64, filter, prefix string) map[string]float64 {
+	if filter == "" {
+		return maps.Clone(rates)
+	}
+	wanted := map[string]bool{}
+	for _, currency := range strings.Split(filter, ",") {
+		wanted[currency] = true
+	}
+	filtered := map[string]float64{}
+	for key, rate := range rates {
+		currency := strings.TrimPrefix(key, prefix)
+		if wanted[currency] {
+			filtered[key] = rate
+		}
+	}
+	return filtered
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+var fiatNames = map[string]string{
+	"USD": "United States Dollar",
+	"INR": "Indian Rupee",
+	"EUR": "Euro",
+	"JPY": "Japanese Yen",
+	"GBP": "British Pound Sterling",
+}
@@ -1,18 +1,17 @@
 package internal
 
-var AllowedFiatCurrencies = map[string]struct{}{
-	"USD": {},
-	"INR": {},
-	"EUR": {},
-	"JPY": {},
-	"GBP": {},
-}
+import "github.com/pavankalyan767/exchange-rate-service/currency"
 
-var AllowedCryptoCurrencies = map[string]struct{}{
-	"BTC": {},
-	"ETH": {},
-	"USDT": {},
-}
+// DefaultFiatCurrencies and DefaultCryptoCurrencies are accepted until the
+// service loads its currencies from configuration.
+var (
+	DefaultFiatCurrencies   = []string{"USD", "INR", "EUR", "JPY", "GBP"}
+	DefaultCryptoCurrencies = []string{"BTC", "ETH", "USDT"}
+)
+
+// Currencies is the registry of accepted currencies that the helpers below
+// consult. The service reloads it through a currency.Loader.
+var Currencies = currency.NewRegistry(DefaultFiatCurrencies, DefaultCryptoCurrencies)
 
 const (
 	// LookbackDays is the maximum number of days for historical data.
@@ -23,18 +22,26 @@ const (
 	BaseCurrency = "USD"
 )
 
+// FiatCurrencies returns the accepted fiat currency codes. The set must not be modified.
+func FiatCurrencies() map[string]struct{} {
+	return Currencies.Codes(currency.Fiat)
+}
+
+// CryptoCurrencies returns the accepted crypto currency codes. The set must not be modified.
+func CryptoCurrencies() map[string]struct{} {
+	return Currencies.Codes(currency.Crypto)
+}
+
 func IsFiatCurrency(currency string) bool {
-	_, exists := AllowedFiatCurrencies[currency]
-	return exists
+	return Currencies.IsFiat(currency)
 }
 
 // IsCryptoCurrency checks if the given currency is a crypto currency.
 func IsCryptoCurrency(currency string) bool {
-	_, exists := AllowedCryptoCurrencies[currency]
-	return exists
+	return Currencies.IsCrypto(currency)
 }
 
 // IsAllowedCurrency checks if the given currency is either a fiat or a crypto currency.
 func IsAllowedCurrency(currency string) bool {
-	return IsFiatCurrency(currency) || IsCryptoCurrency(currency)
+	return Currencies.IsAllowed(currency)
 }
@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
 	"time"
 
 	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
@@ -13,20 +17,31 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/pavankalyan767/exchange-rate-service/cache"
 	"github.com/pavankalyan767/exchange-rate-service/client"
+	"github.com/pavankalyan767/exchange-rate-service/config"
+	"github.com/pavankalyan767/exchange-rate-service/currency"
+	"github.com/pavankalyan767/exchange-rate-service/internal"
+	"github.com/pavankalyan767/exchange-rate-service/provider"
+	"github.com/pavankalyan767/exchange-rate-service/scheduler"
 	service "github.com/pavankalyan767/exchange-rate-service/service"
+	"github.com/pavankalyan767/exchange-rate-service/snapshot"
+	"github.com/pavankalyan767/exchange-rate-service/store"
 	"github.com/pavankalyan767/exchange-rate-service/transport"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+	// Subcommands (export, import) are handled by the CLI instead of starting the service.
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	// Create a logger with a timestamp and caller information.
 	logger := log.NewLogfmtLogger(os.Stderr)
 	logger = log.With(logger, "ts", log.DefaultTimestampUTC)
 	logger = log.With(logger, "caller", log.DefaultCaller)
 
-	err := godotenv.Load("/app/.env")
-	if err != nil {
+	if err := godotenv.Load("/app/.env"); err != nil {
 		logger.Log("Error", "failed to load .env file", "err", err)
 		// Exit if environment variables cannot be loaded, as the service won't function.
 		os.Exit(1)
@@ -52,82 +67,352 @@ func main() {
 		Help:      "The result of each count method.",
 	}, []string{}) // no fields here
 
-	// Load environment variables from .env file.
-	
-
-	// Read API keys and URLs from environment variables.
-	fiatapikey := os.Getenv("FIAT_API_KEY")
-	if fiatapikey == "" {
-		logger.Log("Error", "FIAT_API_KEY environment variable is not set. Exiting.")
+	// Read provider URLs and API keys from environment variables.
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Log("Error", "invalid configuration. Exiting.", "err", err)
 		os.Exit(1)
 	}
-	cryptoapikey := os.Getenv("CRYPTO_API_KEY")
-	if cryptoapikey == "" {
-		logger.Log("Warning", "CRYPTO_API_KEY environment variable is not set. Crypto-related requests will not be available.")
+	if len(cfg.CryptoProviders) == 0 {
+		logger.Log("Warning", "CRYPTO_API_URL environment variable is not set. Crypto-related requests will not be available.")
 	}
-	fiatUrl := os.Getenv("FIAT_API_URL")
-	if fiatUrl == "" {
-		logger.Log("Error", "FIAT_API_URL environment variable is not set. Exiting.")
+
+	// Every provider gets its own API client and circuit breaker, sharing the retry policy and metrics.
+	upstreamAttempts := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "my_group",
+		Subsystem: "exchange-rate-service",
+		Name:      "upstream_attempts_total",
+		Help:      "Number of HTTP attempts made against upstream providers, by status.",
+	}, []string{"provider", "status"})
+	upstreamOutcomes := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "my_group",
+		Subsystem: "exchange-rate-service",
+		Name:      "upstream_requests_total",
+		Help:      "Number of upstream requests, by final outcome after retries.",
+	}, []string{"provider", "outcome"})
+	upstreamMode, err := client.ParseMode(cfg.UpstreamMode)
+	if err != nil {
+		logger.Log("Error", "invalid UPSTREAM_MODE. Exiting.", "err", err)
 		os.Exit(1)
 	}
-	cryptoUrl := os.Getenv("CRYPTO_API_URL")
-	if cryptoUrl == "" {
-		logger.Log("Warning", "CRYPTO_API_URL environment variable is not set. Crypto-related requests will not be available.")
+	if upstreamMode != client.ModeLive {
+		logger.Log("message", "upstream record/replay enabled", "mode", upstreamMode, "fixtures_dir", cfg.FixturesDir)
+	}
+	retryPolicy := client.RetryPolicy{
+		MaxAttempts:    cfg.RetryMaxAttempts,
+		InitialBackoff: cfg.RetryInitialBackoff,
+		MaxBackoff:     cfg.RetryMaxBackoff,
+		Jitter:         cfg.RetryJitter,
+	}
+	// Every attempt and the backoff between them fit in the provider timeout, so
+	// a hung upstream still leaves time to retry.
+	retryPolicy.AttemptTimeout = retryPolicy.AttemptTimeoutWithin(cfg.ProviderTimeout)
+	clientOpts := []client.ClientOption{
+		client.WithRetryPolicy(retryPolicy),
+		client.WithMetrics(upstreamAttempts, upstreamOutcomes),
+		client.WithFixtures(upstreamMode, cfg.FixturesDir),
+	}
+	breakerState := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "my_group",
+		Subsystem: "exchange-rate-service",
+		Name:      "upstream_circuit_breaker_state",
+		Help:      "Circuit breaker state per provider: 0 closed, 1 half-open, 2 open.",
+	}, []string{"provider"})
+	quotaRemaining := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "my_group",
+		Subsystem: "exchange-rate-service",
+		Name:      "upstream_quota_remaining",
+		Help:      "Requests left in each provider's monthly quota.",
+	}, []string{"provider"})
+	var upstreams []transport.UpstreamClient
+	// newAPIClient builds the client for one provider. It returns the API key to put
+	// in the URL, which is empty when the provider takes its key in a header.
+	newAPIClient := func(kind string, p config.ProviderConfig) (*client.APIClient, string) {
+		if p.APIKey == "" && upstreamMode != client.ModeReplay {
+			logger.Log("Warning", kind+" provider has no API key configured", "provider", p.Name)
+		}
+
+		opts := slices.Clone(clientOpts)
+		breaker := client.NewCircuitBreaker(p.Name, cfg.BreakerFailureThreshold, cfg.BreakerOpenTimeout, breakerState)
+		opts = append(opts, client.WithCircuitBreaker(breaker))
+
+		if p.MonthlyQuota > 0 {
+			quota, err := client.NewQuota(p.Name, p.MonthlyQuota, cfg.QuotaStateDir, quotaRemaining)
+			if err != nil {
+				logger.Log("Error", "failed to load provider quota. Exiting.", "provider", p.Name, "err", err)
+				os.Exit(1)
+			}
+			opts = append(opts, client.WithQuota(quota))
+		}
+
+		queryKey := p.APIKey
+		if p.APIKeyHeader != "" {
+			opts = append(opts, client.WithHeader(p.APIKeyHeader, p.APIKey))
+			queryKey = ""
+		}
+
+		apiClient := client.NewAPIClient(p.Name, logger, opts...)
+		upstreams = append(upstreams, transport.UpstreamClient{Kind: kind, Client: apiClient})
+		return apiClient, queryKey
 	}
 
-	// Initialize the API client and caches.
-	apiClient := client.NewAPIClient(fiatUrl, cryptoUrl, fiatapikey, cryptoapikey, logger)
-	fiatCache := cache.NewCache(5*time.Minute, 10*time.Minute,logger)
-	cryptoCache := cache.NewCache(5*time.Minute, 10*time.Minute,logger)
+	// Initialize the upstream rate providers, in priority order, and caches.
+	var fiatProviders []provider.RateProvider
+	for _, p := range cfg.FiatProviders {
+		apiClient, queryKey := newAPIClient(service.FiatRates, p)
+		fiatProviders = append(fiatProviders, provider.NewCurrencyLayer(p.URL, queryKey, apiClient))
+	}
+	var cryptoProviders []provider.RateProvider
+	for _, p := range cfg.CryptoProviders {
+		apiClient, queryKey := newAPIClient(service.CryptoRates, p)
+		cryptoProviders = append(cryptoProviders, provider.NewCoinLayer(p.URL, queryKey, apiClient))
+	}
+	cacheLookups := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "my_group",
+		Subsystem: "exchange-rate-service",
+		Name:      "cache_lookups_total",
+		Help:      "Number of cache lookups, by result (hit, stale, expired or miss).",
+	}, []string{"cache", "result"})
+	cacheEvictions := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "my_group",
+		Subsystem: "exchange-rate-service",
+		Name:      "cache_evictions_total",
+		Help:      "Number of entries evicted from a cache, by reason (capacity or expired).",
+	}, []string{"cache", "reason"})
+	cacheEntries := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "my_group",
+		Subsystem: "exchange-rate-service",
+		Name:      "cache_entries",
+		Help:      "Number of entries held by a cache.",
+	}, []string{"cache"})
+	cacheBytes := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "my_group",
+		Subsystem: "exchange-rate-service",
+		Name:      "cache_bytes",
+		Help:      "Estimated size of the values held by a cache, in bytes.",
+	}, []string{"cache"})
+	cacheOpts := func(name string) []cache.Option {
+		return []cache.Option{
+			cache.WithMaxEntries(cfg.CacheMaxEntries),
+			cache.WithMaxBytes(cfg.CacheMaxBytes),
+			cache.WithMaxStale(cfg.CacheMaxStale),
+			cache.WithShards(cfg.CacheShards),
+			cache.WithMetrics(name, cacheLookups, cacheEvictions, cacheEntries, cacheBytes),
+		}
+	}
+	fiatCache := cache.NewRateCache(5*time.Minute, 10*time.Minute, logger, cacheOpts(service.FiatRates)...)
+	cryptoCache := cache.NewRateCache(5*time.Minute, 10*time.Minute, logger, cacheOpts(service.CryptoRates)...)
+
+	// The store keeps every fetched rate map on disk; the caches are a hot layer in front of it.
+	rateStore, err := store.Open(cfg.StorePath)
+	if err != nil {
+		logger.Log("Error", "failed to open rate store. Exiting.", "err", err)
+		os.Exit(1)
+	}
+
+	// The background jobs are registered below; the service already needs the
+	// scheduler to trigger a refresh when it serves stale rates.
+	jobRuns := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "my_group",
+		Subsystem: "exchange-rate-service",
+		Name:      "job_runs_total",
+		Help:      "Number of background job runs, by outcome.",
+	}, []string{"job", "outcome"})
+	jobs := scheduler.New(log.With(logger, "component", "scheduler"), scheduler.WithMetrics(jobRuns))
+
+	// The fetcher publishes each refresh as one version, and every request
+	// reads its rates from a single version.
+	publisher := service.NewPublisher()
 
 	// Initialize the core service.
 	var svc service.ExchangeRateService
-	svc = service.NewExchangeRateServiceImpl(fiatCache, cryptoCache)
+	svc = service.NewExchangeRateServiceImpl(fiatCache, cryptoCache,
+		service.WithStoreFallback(rateStore),
+		service.WithPublishedReads(publisher),
+		service.WithStaleRefresh(func(kind string) { jobs.Trigger(kind + "-live") }, cfg.StaleRefreshInterval),
+	)
 
 	svc = service.NewLoggingMiddleware(logger, svc)
 	svc = service.NewInstrumentingMiddleware(requestCount, requestLatency, countResult, svc)
 
 	// Initialize the rate fetcher.
-	rate_fetcher := service.NewRateFetcher(apiClient, fiatCache, cryptoCache,logger)
+	fetcherOpts := []service.FetcherOption{
+		service.WithProviderTimeout(cfg.ProviderTimeout),
+		service.WithLiveTTL(cfg.LiveRateTTL),
+		service.WithHistoryHorizon(cfg.HistoryHorizonDays),
+		service.WithStore(rateStore),
+		service.WithPublisher(publisher),
+		service.WithHistoryChunks(cfg.HistoryChunkDays, cfg.HistoryFetchConcurrency),
+		service.WithCoverageMetric(kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "my_group",
+			Subsystem: "exchange-rate-service",
+			Name:      "history_coverage_percent",
+			Help:      "Percentage of the history window for which every allowed pair has a rate.",
+		}, []string{"kind"})),
+		service.WithUpdateMetric(kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "my_group",
+			Subsystem: "exchange-rate-service",
+			Name:      "rates_last_update_timestamp_seconds",
+			Help:      "Unix time of the last successful live rate update, by kind.",
+		}, []string{"kind"})),
+	}
+	if cfg.FetchMode == service.FetchModeConsensus {
+		consensusSpread := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "my_group",
+			Subsystem: "exchange-rate-service",
+			Name:      "provider_spread_ratio",
+			Help:      "Relative deviation of each provider's quote from the consensus median.",
+		}, []string{"kind", "provider", "pair"})
+		consensusOutliers := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "my_group",
+			Subsystem: "exchange-rate-service",
+			Name:      "provider_outliers_total",
+			Help:      "Number of provider quotes discarded as outliers.",
+		}, []string{"kind", "provider"})
+		fetcherOpts = append(fetcherOpts,
+			service.WithConsensus(cfg.ConsensusMaxDeviation, cfg.ConsensusQuorum),
+			service.WithConsensusMetrics(consensusSpread, consensusOutliers),
+		)
+	}
+	rate_fetcher := service.NewRateFetcher(fiatProviders, cryptoProviders, fiatCache, cryptoCache, logger, fetcherOpts...)
 
 	// --- Polling Logic ---
-	// Create a single context to manage all background goroutines.
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel() // This will cancel the context when main() returns.
+	// Create a single context to manage all background goroutines. It is
+	// cancelled on SIGINT or SIGTERM, which starts the shutdown sequence.
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 
-	// Perform an initial fetch for fiat and crypto rates.
-	if err := rate_fetcher.LiveRate(ctx); err != nil {
-		logger.Log("Error", fmt.Sprintf("failed to fetch initial fiat rates: %v", err))
+	// The accepted currencies come from configuration and, optionally, from what
+	// the providers support. They are loaded before the first fetch and reloaded
+	// on SIGHUP or every CURRENCY_RELOAD_INTERVAL, without a restart.
+	fiatCodes, cryptoCodes := cfg.FiatCurrencies, cfg.CryptoCurrencies
+	if len(fiatCodes) == 0 {
+		fiatCodes = internal.DefaultFiatCurrencies
 	}
-	if err := rate_fetcher.CryptoRate(ctx); err != nil {
-		logger.Log("Error", fmt.Sprintf("failed to fetch initial crypto rates: %v", err))
+	if len(cryptoCodes) == 0 {
+		cryptoCodes = internal.DefaultCryptoCurrencies
 	}
-	logger.Log("message", "Initial fetch of live rates complete.")
-
-	// Start a single background goroutine for hourly polling of both rate types.
-	if err := rate_fetcher.HistoricalRate(ctx); err != nil {
-		logger.Log("Failed to fetch historical rates on startup: %v", err)
+	currencyOpts := []currency.LoaderOption{
+		currency.WithCodes(fiatCodes, cryptoCodes),
+		currency.WithFile(cfg.CurrenciesFile),
+		currency.WithRequired(internal.BaseCurrency),
 	}
-	if err := rate_fetcher.CryptoRate(ctx); err != nil {
-		logger.Log("Error", fmt.Sprintf("error during hourly crypto rate polling: %v", err))
+	if cfg.CurrencyDiscovery {
+		for _, p := range fiatProviders {
+			currencyOpts = append(currencyOpts, currency.WithDiscovery(currency.Fiat, p))
+		}
+		for _, p := range cryptoProviders {
+			currencyOpts = append(currencyOpts, currency.WithDiscovery(currency.Crypto, p))
+		}
 	}
-
-	go func() {
-		ticker := time.NewTicker(1 * time.Hour)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			logger.Log("message", "Hourly poll initiated...")
-			// Use the main context for the API calls.
-			if err := rate_fetcher.LiveRate(ctx); err != nil {
-				logger.Log("Error", fmt.Sprintf("error during hourly fiat rate polling: %v", err))
+	currencies := currency.NewLoader(internal.Currencies, log.With(logger, "component", "currencies"), currencyOpts...)
+	if _, err := currencies.Reload(ctx); err != nil {
+		logger.Log("Error", "invalid currency configuration. Exiting.", "err", err)
+		os.Exit(1)
+	}
+	var currencySchedule scheduler.Schedule
+	if cfg.CurrencyReloadInterval > 0 {
+		currencySchedule = scheduler.Every(cfg.CurrencyReloadInterval)
+	}
+	mustAddJob(logger, jobs, &scheduler.Job{
+		Name:     "currencies",
+		Schedule: currencySchedule,
+		Run: func(ctx context.Context) error {
+			changed, err := currencies.Reload(ctx)
+			if changed {
+				// Fetch the rates of newly accepted currencies without waiting for the next poll.
+				jobs.Trigger("fiat-live")
+				jobs.Trigger("crypto-live")
 			}
-			if err := rate_fetcher.CryptoRate(ctx); err != nil {
-				logger.Log("Error", fmt.Sprintf("error during hourly crypto rate polling: %v", err))
+			return err
+		},
+	})
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-hangup:
+				logger.Log("message", "SIGHUP received, reloading currencies.")
+				jobs.Trigger("currencies")
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
 
+	// Each rate source is polled on its own schedule, and less often when an
+	// upstream quota is close to exhaustion.
+	fiatSchedule, err := pollSchedule(cfg.FiatPollInterval, cfg.FiatPollCron)
+	if err != nil {
+		logger.Log("Error", "invalid fiat polling schedule. Exiting.", "err", err)
+		os.Exit(1)
+	}
+	mustAddJob(logger, jobs, &scheduler.Job{
+		Name:     "fiat-live",
+		Schedule: fiatSchedule,
+		Jitter:   cfg.FiatPollJitter,
+		MinDelay: quotaDelay(rate_fetcher, service.FiatRates, fiatSchedule),
+		Run:      rate_fetcher.LiveRate,
+	})
+	initialJobs := []string{"fiat-live"}
+	if len(cryptoProviders) > 0 {
+		cryptoSchedule, err := pollSchedule(cfg.CryptoPollInterval, cfg.CryptoPollCron)
+		if err != nil {
+			logger.Log("Error", "invalid crypto polling schedule. Exiting.", "err", err)
+			os.Exit(1)
+		}
+		mustAddJob(logger, jobs, &scheduler.Job{
+			Name:     "crypto-live",
+			Schedule: cryptoSchedule,
+			Jitter:   cfg.CryptoPollJitter,
+			MinDelay: quotaDelay(rate_fetcher, service.CryptoRates, cryptoSchedule),
+			Run:      rate_fetcher.CryptoRate,
+		})
+		initialJobs = append(initialJobs, "crypto-live")
+	}
+	// The whole history window is fetched at startup; afterwards a daily job
+	// adds the day that just ended and evicts the oldest days.
+	mustAddJob(logger, jobs, &scheduler.Job{
+		Name: "history",
+		Run:  rate_fetcher.HistoricalRate,
+	})
+	initialJobs = append(initialJobs, "history")
+	historySchedule, err := scheduler.ParseCron(cfg.HistoryRefreshCron)
+	if err != nil {
+		logger.Log("Error", "invalid history refresh schedule. Exiting.", "err", err)
+		os.Exit(1)
+	}
+	mustAddJob(logger, jobs, &scheduler.Job{
+		Name:     "history-refresh",
+		Schedule: historySchedule,
+		Run:      rate_fetcher.RefreshHistory,
+	})
+	mustAddJob(logger, jobs, &scheduler.Job{
+		Name:     "history-reconcile",
+		Schedule: scheduler.Every(cfg.ReconcileInterval),
+		Run:      rate_fetcher.Reconcile,
+	})
+
+	// Seed the caches and the store from a snapshot archive, if one is configured.
+	if cfg.SeedSnapshot != "" {
+		if err := seedSnapshot(rate_fetcher, cfg.SeedSnapshot); err != nil {
+			logger.Log("Error", "failed to import seed snapshot. Exiting.", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	// Perform an initial fetch of every job before serving requests.
+	for _, name := range initialJobs {
+		if err := jobs.RunNow(ctx, name); err != nil {
+			logger.Log("Error", fmt.Sprintf("initial %s fetch failed: %v", name, err))
+		}
+	}
+	logger.Log("message", "Initial fetch of rates complete.")
+
+	jobs.Start(ctx)
+
 	// --- Endpoint and Middleware Setup ---
 
 	// Create all endpoints from the service.
@@ -171,12 +456,115 @@ func main() {
 	http.Handle("/fetch", fetchHandler)
 	http.Handle("/convert", convertHandler)
 	http.Handle("/history", historyHandler)
+	http.Handle("/status", transport.NewStatusHandler(upstreams))
+	http.Handle("/currencies", transport.NewCurrenciesHandler(internal.Currencies))
 	http.Handle("/metrics", promhttp.Handler())
+	if cfg.AdminToken == "" {
+		logger.Log("Warning", "ADMIN_TOKEN is not set. The admin endpoints are disabled.")
+	}
+	http.Handle("/admin/snapshot", transport.NewSnapshotHandler(rate_fetcher, cfg.AdminToken))
 
 	// Start the HTTP server.
+	server := &http.Server{Addr: ":8080"}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
 	logger.Log("message", "HTTP server listening", "port", "8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		logger.Log("Error", "server failed to start", "err", err)
+
+	exitCode := 0
+	select {
+	case err := <-serverErr:
+		logger.Log("Error", "server failed", "err", err)
+		exitCode = 1
+		cancel()
+	case <-ctx.Done():
+		logger.Log("message", "Shutdown signal received.")
+	}
+	if err := shutdown(logger, cfg.ShutdownTimeout, server, jobs, rateStore, fiatCache, cryptoCache); err != nil {
+		exitCode = 1
+	}
+	os.Exit(exitCode)
+}
+
+// shutdown stops the service in dependency order: the HTTP server drains
+// in-flight requests, the scheduler waits for running jobs (whose context is
+// already cancelled), then the caches and finally the store are closed. Every
+// step is attempted; the errors of those that failed are returned joined.
+func shutdown(logger log.Logger, timeout time.Duration, server *http.Server, jobs *scheduler.Scheduler, rateStore *store.Store, caches ...*cache.RateCache) error {
+	var errs []error
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Log("Error", "HTTP server did not shut down cleanly", "err", err)
+		errs = append(errs, err)
+	}
+	logger.Log("message", "HTTP server stopped.")
+
+	jobs.Wait()
+	logger.Log("message", "Background jobs stopped.")
+
+	for _, c := range caches {
+		c.Close()
+	}
+
+	if err := rateStore.Close(); err != nil {
+		logger.Log("Error", "failed to close rate store", "err", err)
+		errs = append(errs, err)
+	}
+	logger.Log("message", "Shutdown complete.")
+
+	return errors.Join(errs...)
+}
+
+// pollSchedule returns the cron schedule if one is configured, or else the fixed interval.
+func pollSchedule(interval time.Duration, cronSpec string) (scheduler.Schedule, error) {
+	if cronSpec != "" {
+		return scheduler.ParseCron(cronSpec)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("poll interval must be positive, got %s", interval)
+	}
+	return scheduler.Every(interval), nil
+}
+
+// quotaDelay holds a polling job back when the upstream quota of its providers
+// would not last at the schedule's normal pace.
+func quotaDelay(rf *service.RateFetcher, kind string, schedule scheduler.Schedule) func() time.Duration {
+	period := scheduler.Period(schedule)
+	return func() time.Duration {
+		if interval := rf.PollInterval(kind, period); interval > period {
+			return interval
+		}
+		return 0
+	}
+}
+
+// mustAddJob registers job with the scheduler, exiting on failure.
+func mustAddJob(logger log.Logger, jobs *scheduler.Scheduler, job *scheduler.Job) {
+	if err := jobs.Add(job); err != nil {
+		logger.Log("Error", "failed to register job. Exiting.", "job", job.Name, "err", err)
 		os.Exit(1)
 	}
 }
+
+// seedSnapshot imports the snapshot archive at path into the rate fetcher.
+func seedSnapshot(rf *service.RateFetcher, path string) error {
+	format, err := snapshot.ParseFormat("", path)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	snap, err := snapshot.Read(file, format)
+	if err != nil {
+		return err
+	}
+	_, err = rf.Import(snap)
+	return err
+}
@@ -0,0 +1,157 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pavankalyan767/exchange-rate-service/client"
+	"github.com/pavankalyan767/exchange-rate-service/internal"
+)
+
+// CoinLayer is a crypto RateProvider for coinlayer-style APIs, which return
+// the price of each coin in the target currency under a `rates` object.
+type CoinLayer struct {
+	baseURL   string
+	apiKey    string
+	apiClient *client.APIClient
+}
+
+// NewCoinLayer creates a coinlayer-style provider that issues its requests through apiClient.
+// apiKey may be empty when apiClient already sends the key in a header.
+func NewCoinLayer(baseURL, apiKey string, apiClient *client.APIClient) *CoinLayer {
+	return &CoinLayer{
+		baseURL:   baseURL,
+		apiKey:    apiKey,
+		apiClient: apiClient,
+	}
+}
+
+type coinLayerLiveResponse struct {
+	Success *bool               `json:"success,omitempty"`
+	Error   *currencyLayerError `json:"error,omitempty"`
+	Rates   map[string]float64  `json:"rates"`
+}
+
+type coinLayerHistoryResponse struct {
+	Success *bool                         `json:"success,omitempty"`
+	Error   *currencyLayerError           `json:"error,omitempty"`
+	Rates   map[string]map[string]float64 `json:"rates"`
+}
+
+type coinLayerListResponse struct {
+	Success *bool               `json:"success,omitempty"`
+	Error   *currencyLayerError `json:"error,omitempty"`
+	Crypto  map[string]struct {
+		Symbol   string `json:"symbol"`
+		FullName string `json:"name_full"`
+	} `json:"crypto"`
+}
+
+// Name implements RateProvider.
+func (p *CoinLayer) Name() string {
+	return p.apiClient.Name()
+}
+
+// Available implements Availability.
+func (p *CoinLayer) Available() bool {
+	return p.apiClient.Available()
+}
+
+// Budget implements Budgeted.
+func (p *CoinLayer) Budget() (int, time.Duration, bool) {
+	return p.apiClient.Budget()
+}
+
+// LiveRates implements RateProvider.
+func (p *CoinLayer) LiveRates(ctx context.Context, currencies map[string]struct{}) (map[string]float64, error) {
+	query := authQuery(p.apiKey)
+	query.Set("target", internal.BaseCurrency)
+	query.Set("symbols", joinCurrencies(currencies))
+
+	resp, err := p.apiClient.Get(ctx, buildURL(p.baseURL, "live", query))
+	if err != nil {
+		return nil, fmt.Errorf("error getting response from api client for crypto rates: %w", err)
+	}
+
+	var liveResponse coinLayerLiveResponse
+	if err := json.Unmarshal(resp, &liveResponse); err != nil {
+		return nil, fmt.Errorf("error unmarshalling crypto rate response: %w", err)
+	}
+	if err := checkCurrencyLayerError(liveResponse.Success, liveResponse.Error); err != nil {
+		return nil, err
+	}
+
+	rates := make(map[string]float64, len(currencies))
+	for coin, rate := range liveResponse.Rates {
+		if _, ok := currencies[coin]; ok {
+			rates[coin+internal.BaseCurrency] = rate
+		}
+	}
+	return rates, nil
+}
+
+// MaxHistoryDays implements HistoryLimited: timeframe queries span at most 365 days.
+func (p *CoinLayer) MaxHistoryDays() int {
+	return 365
+}
+
+// HistoricalRates implements RateProvider, using the timeframe endpoint.
+// Results are keyed by date, then by coin+USD as in LiveRates.
+func (p *CoinLayer) HistoricalRates(ctx context.Context, start, end time.Time, currencies map[string]struct{}) (map[string]map[string]float64, error) {
+	query := authQuery(p.apiKey)
+	query.Set("target", internal.BaseCurrency)
+	query.Set("symbols", joinCurrencies(currencies))
+	query.Set("start_date", start.Format(internal.DateFormat))
+	query.Set("end_date", end.Format(internal.DateFormat))
+
+	resp, err := p.apiClient.Get(ctx, buildURL(p.baseURL, "timeframe", query))
+	if err != nil {
+		return nil, fmt.Errorf("error getting response from api client for historical crypto rates: %w", err)
+	}
+
+	var historyResponse coinLayerHistoryResponse
+	if err := json.Unmarshal(resp, &historyResponse); err != nil {
+		return nil, fmt.Errorf("error unmarshalling historical crypto rate response: %w", err)
+	}
+	if err := checkCurrencyLayerError(historyResponse.Success, historyResponse.Error); err != nil {
+		return nil, err
+	}
+
+	history := make(map[string]map[string]float64, len(historyResponse.Rates))
+	for date, prices := range historyResponse.Rates {
+		rates := make(map[string]float64, len(currencies))
+		for coin, rate := range prices {
+			if _, ok := currencies[coin]; ok {
+				rates[coin+internal.BaseCurrency] = rate
+			}
+		}
+		history[date] = rates
+	}
+	return history, nil
+}
+
+// SupportedCurrencies implements RateProvider.
+func (p *CoinLayer) SupportedCurrencies(ctx context.Context) (map[string]string, error) {
+	query := authQuery(p.apiKey)
+
+	resp, err := p.apiClient.Get(ctx, buildURL(p.baseURL, "list", query))
+	if err != nil {
+		return nil, fmt.Errorf("error getting response from api client for crypto list: %w", err)
+	}
+
+	var listResponse coinLayerListResponse
+	if err := json.Unmarshal(resp, &listResponse); err != nil {
+		return nil, fmt.Errorf("error unmarshalling crypto list response: %w", err)
+	}
+	if err := checkCurrencyLayerError(listResponse.Success, listResponse.Error); err != nil {
+		return nil, err
+	}
+
+	currencies := make(map[string]string, len(listResponse.Crypto))
+	for code, coin := range listResponse.Crypto {
+		currencies[code] = coin.FullName
+	}
+	return currencies, nil
+}
@@ -0,0 +1,167 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pavankalyan767/exchange-rate-service/client"
+	"github.com/pavankalyan767/exchange-rate-service/internal"
+)
+
+// CurrencyLayer is a fiat RateProvider for currencylayer-style APIs
+// (currencylayer.com, exchangerate.host), which expose `live`, `timeframe`
+// and `list` endpoints authenticated with an access_key query parameter.
+type CurrencyLayer struct {
+	baseURL   string
+	apiKey    string
+	apiClient *client.APIClient
+}
+
+// NewCurrencyLayer creates a currencylayer-style provider that issues its requests through apiClient.
+// apiKey may be empty when apiClient already sends the key in a header.
+func NewCurrencyLayer(baseURL, apiKey string, apiClient *client.APIClient) *CurrencyLayer {
+	return &CurrencyLayer{
+		baseURL:   baseURL,
+		apiKey:    apiKey,
+		apiClient: apiClient,
+	}
+}
+
+type currencyLayerError struct {
+	Code int    `json:"code"`
+	Info string `json:"info"`
+}
+
+type currencyLayerLiveResponse struct {
+	Success *bool               `json:"success,omitempty"`
+	Error   *currencyLayerError `json:"error,omitempty"`
+	Quotes  map[string]float64  `json:"quotes"`
+}
+
+type currencyLayerHistoryResponse struct {
+	Success *bool                         `json:"success,omitempty"`
+	Error   *currencyLayerError           `json:"error,omitempty"`
+	Quotes  map[string]map[string]float64 `json:"quotes"`
+}
+
+type currencyLayerListResponse struct {
+	Success    *bool               `json:"success,omitempty"`
+	Error      *currencyLayerError `json:"error,omitempty"`
+	Currencies map[string]string   `json:"currencies"`
+}
+
+// checkCurrencyLayerError converts the error object the API returns alongside a 200 status into a Go error.
+func checkCurrencyLayerError(success *bool, apiErr *currencyLayerError) error {
+	if apiErr != nil {
+		return fmt.Errorf("upstream error %d: %s", apiErr.Code, apiErr.Info)
+	}
+	if success != nil && !*success {
+		return errors.New("upstream reported an unsuccessful response")
+	}
+	return nil
+}
+
+// Name implements RateProvider.
+func (p *CurrencyLayer) Name() string {
+	return p.apiClient.Name()
+}
+
+// Available implements Availability.
+func (p *CurrencyLayer) Available() bool {
+	return p.apiClient.Available()
+}
+
+// Budget implements Budgeted.
+func (p *CurrencyLayer) Budget() (int, time.Duration, bool) {
+	return p.apiClient.Budget()
+}
+
+// MaxHistoryDays implements HistoryLimited: timeframe queries span at most 365 days.
+func (p *CurrencyLayer) MaxHistoryDays() int {
+	return 365
+}
+
+// LiveRates implements RateProvider.
+func (p *CurrencyLayer) LiveRates(ctx context.Context, currencies map[string]struct{}) (map[string]float64, error) {
+	query := authQuery(p.apiKey)
+	query.Set("source", internal.BaseCurrency)
+	query.Set("currencies", joinCurrencies(currencies))
+
+	resp, err := p.apiClient.Get(ctx, buildURL(p.baseURL, "live", query))
+	if err != nil {
+		return nil, fmt.Errorf("error getting response from api client for live rates: %w", err)
+	}
+
+	var liveResponse currencyLayerLiveResponse
+	if err := json.Unmarshal(resp, &liveResponse); err != nil {
+		return nil, fmt.Errorf("error unmarshalling live rate response: %w", err)
+	}
+	if err := checkCurrencyLayerError(liveResponse.Success, liveResponse.Error); err != nil {
+		return nil, err
+	}
+
+	return filterQuotes(liveResponse.Quotes, currencies), nil
+}
+
+// HistoricalRates implements RateProvider.
+func (p *CurrencyLayer) HistoricalRates(ctx context.Context, start, end time.Time, currencies map[string]struct{}) (map[string]map[string]float64, error) {
+	query := authQuery(p.apiKey)
+	query.Set("source", internal.BaseCurrency)
+	query.Set("currencies", joinCurrencies(currencies))
+	query.Set("start_date", start.Format(internal.DateFormat))
+	query.Set("end_date", end.Format(internal.DateFormat))
+
+	resp, err := p.apiClient.Get(ctx, buildURL(p.baseURL, "timeframe", query))
+	if err != nil {
+		return nil, fmt.Errorf("error getting response from api client for historical rates: %w", err)
+	}
+
+	var historyResponse currencyLayerHistoryResponse
+	if err := json.Unmarshal(resp, &historyResponse); err != nil {
+		return nil, fmt.Errorf("error unmarshalling historical rate response: %w", err)
+	}
+	if err := checkCurrencyLayerError(historyResponse.Success, historyResponse.Error); err != nil {
+		return nil, err
+	}
+
+	rates := make(map[string]map[string]float64, len(historyResponse.Quotes))
+	for date, quotes := range historyResponse.Quotes {
+		rates[date] = filterQuotes(quotes, currencies)
+	}
+	return rates, nil
+}
+
+// SupportedCurrencies implements RateProvider.
+func (p *CurrencyLayer) SupportedCurrencies(ctx context.Context) (map[string]string, error) {
+	query := authQuery(p.apiKey)
+
+	resp, err := p.apiClient.Get(ctx, buildURL(p.baseURL, "list", query))
+	if err != nil {
+		return nil, fmt.Errorf("error getting response from api client for currency list: %w", err)
+	}
+
+	var listResponse currencyLayerListResponse
+	if err := json.Unmarshal(resp, &listResponse); err != nil {
+		return nil, fmt.Errorf("error unmarshalling currency list response: %w", err)
+	}
+	if err := checkCurrencyLayerError(listResponse.Success, listResponse.Error); err != nil {
+		return nil, err
+	}
+
+	return listResponse.Currencies, nil
+}
+
+// filterQuotes keeps only the USD-based quotes for the requested currencies.
+func filterQuotes(quotes map[string]float64, currencies map[string]struct{}) map[string]float64 {
+	filtered := make(map[string]float64, len(currencies))
+	for currency := range currencies {
+		key := internal.BaseCurrency + currency
+		if rate, ok := quotes[key]; ok {
+			filtered[key] = rate
+		}
+	}
+	return filtered
+}
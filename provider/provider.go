@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrNotSupported is returned by providers for operations their upstream API does not offer.
+var ErrNotSupported = errors.New("operation not supported by provider")
+
+// RateProvider is an upstream source of exchange rates.
+// Implementations translate their API's response shape into maps keyed by
+// currency pair, in the same layout the rate caches use: fiat providers return
+// internal.BaseCurrency+target (e.g. "USDINR"), crypto providers return
+// coin+internal.BaseCurrency (e.g. "BTCUSD").
+type RateProvider interface {
+	// Name identifies the provider in logs and metrics.
+	Name() string
+
+	// LiveRates returns the latest rates for the requested currencies.
+	LiveRates(ctx context.Context, currencies map[string]struct{}) (map[string]float64, error)
+
+	// HistoricalRates returns the rates for every day between start and end
+	// (inclusive), keyed by date in internal.DateFormat.
+	HistoricalRates(ctx context.Context, start, end time.Time, currencies map[string]struct{}) (map[string]map[string]float64, error)
+
+	// SupportedCurrencies returns the currency codes offered by the upstream,
+	// mapped to their display names.
+	SupportedCurrencies(ctx context.Context) (map[string]string, error)
+}
+
+// Availability is implemented by providers that can tell, without making a
+// request, that their upstream should not be called right now (for example
+// because its circuit breaker is open).
+type Availability interface {
+	Available() bool
+}
+
+// IsAvailable reports whether p may be called. Providers that do not implement
+// Availability are always considered available.
+func IsAvailable(p RateProvider) bool {
+	if a, ok := p.(Availability); ok {
+		return a.Available()
+	}
+	return true
+}
+
+// authQuery returns a query carrying apiKey as access_key. Providers configured
+// to send their key in a header have an empty apiKey and get an empty query.
+func authQuery(apiKey string) url.Values {
+	query := url.Values{}
+	if apiKey != "" {
+		query.Set("access_key", apiKey)
+	}
+	return query
+}
+
+// Budgeted is implemented by providers whose upstream enforces a request quota.
+// Budget returns the requests left and the time until the quota resets; ok is
+// false when no quota is configured.
+type Budgeted interface {
+	Budget() (remaining int, resetsIn time.Duration, ok bool)
+}
+
+// HistoryLimited is implemented by providers that cap the number of days a
+// single historical request may span. The rate fetcher splits longer ranges
+// into chunks of at most MaxHistoryDays days.
+type HistoryLimited interface {
+	MaxHistoryDays() int
+}
+
+// buildURL joins the endpoint onto the provider base URL and appends the query.
+func buildURL(baseURL, endpoint string, query url.Values) string {
+	return strings.TrimSuffix(baseURL, "/") + "/" + endpoint + "?" + query.Encode()
+}
+
+// joinCurrencies returns the currency set as a sorted, comma-separated list.
+func joinCurrencies(currencies map[string]struct{}) string {
+	codes := make([]string, 0, len(currencies))
+	for currency := range currencies {
+		codes = append(codes, currency)
+	}
+	slices.Sort(codes)
+	return strings.Join(codes, ",")
+}
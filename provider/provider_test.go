@@ -0,0 +1,83 @@
+package provider_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/pavankalyan767/exchange-rate-service/client"
+	"github.com/pavankalyan767/exchange-rate-service/fakeupstream"
+	"github.com/pavankalyan767/exchange-rate-service/internal"
+	"github.com/pavankalyan767/exchange-rate-service/provider"
+)
+
+func newAPIClient() *client.APIClient {
+	return client.NewAPIClient("fake", log.NewLogfmtLogger(os.Stderr), client.WithRetryPolicy(client.RetryPolicy{MaxAttempts: 1}))
+}
+
+func TestCurrencyLayer_LiveRatesReturnsPartialQuotes(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+	upstream.SetFiatQuotes(map[string]float64{"USDINR": 83.0})
+
+	p := provider.NewCurrencyLayer(upstream.FiatURL(), "key", newAPIClient())
+	rates, err := p.LiveRates(context.Background(), internal.FiatCurrencies())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(rates) != 1 || rates["USDINR"] != 83.0 {
+		t.Errorf("expected only USDINR 83.00, got %v", rates)
+	}
+}
+
+func TestCurrencyLayer_ReportsUpstreamErrors(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+	upstream.Enqueue(fakeupstream.FiatLive, fakeupstream.APIError, fakeupstream.MalformedJSON)
+
+	p := provider.NewCurrencyLayer(upstream.FiatURL(), "key", newAPIClient())
+	if _, err := p.LiveRates(context.Background(), internal.FiatCurrencies()); err == nil {
+		t.Errorf("expected error for API error object")
+	}
+	if _, err := p.LiveRates(context.Background(), internal.FiatCurrencies()); err == nil {
+		t.Errorf("expected error for malformed JSON")
+	}
+}
+
+func TestCurrencyLayer_HistoricalRates(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+
+	end := time.Now()
+	start := end.AddDate(0, 0, -2)
+	upstream.SkipHistory(start.Format(internal.DateFormat))
+
+	p := provider.NewCurrencyLayer(upstream.FiatURL(), "key", newAPIClient())
+	history, err := p.HistoricalRates(context.Background(), start, end, internal.FiatCurrencies())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(history) != 2 {
+		t.Errorf("expected 2 days of history, got %d", len(history))
+	}
+}
+
+func TestCoinLayer_LiveRatesKeysByCoin(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+
+	p := provider.NewCoinLayer(upstream.CryptoURL(), "key", newAPIClient())
+	rates, err := p.LiveRates(context.Background(), internal.CryptoCurrencies())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if rates["BTCUSD"] != 30000.0 {
+		t.Errorf("expected BTCUSD 30000.00, got %v", rates)
+	}
+}
@@ -0,0 +1,247 @@
+// Package scheduler runs background jobs, such as the rate fetches, each on its
+// own interval or cron schedule.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/log"
+	"github.com/robfig/cron/v3"
+)
+
+// ErrRunning is returned by RunNow when the job is already running.
+var ErrRunning = errors.New("job is already running")
+
+// Schedule decides when a job runs next.
+type Schedule interface {
+	// Next returns the next run time after t.
+	Next(t time.Time) time.Time
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// Every runs a job at a fixed interval, measured from the end of the previous run.
+func Every(interval time.Duration) Schedule {
+	return every(interval)
+}
+
+// ParseCron parses a standard five-field cron expression (e.g. "5 0 * * *"),
+// also accepting descriptors such as "@hourly" and "@every 10m".
+func ParseCron(spec string) (Schedule, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+	}
+	return schedule, nil
+}
+
+// Period estimates the nominal time between two consecutive runs of schedule.
+func Period(schedule Schedule) time.Duration {
+	first := schedule.Next(time.Now())
+	return schedule.Next(first).Sub(first)
+}
+
+// Job is a unit of background work.
+type Job struct {
+	// Name identifies the job in logs and metrics.
+	Name string
+	// Schedule decides when the job runs. A nil schedule only runs the job on
+	// start (if RunOnStart) and when triggered.
+	Schedule Schedule
+	// Jitter adds a random delay of up to this much to every scheduled run, so
+	// that instances started together do not hit the upstream at the same moment.
+	Jitter time.Duration
+	// MinDelay, if set, is called after every run and holds the next run back
+	// by at least the duration it returns (e.g. to spare an upstream quota).
+	MinDelay func() time.Duration
+	// RunOnStart runs the job as soon as the scheduler starts.
+	RunOnStart bool
+	// Run does the work. It must return when ctx is cancelled.
+	Run func(ctx context.Context) error
+
+	running atomic.Bool
+	trigger chan struct{}
+}
+
+// Scheduler runs jobs on their schedules until its context is cancelled.
+// A job never runs concurrently with itself: a run that comes due while the
+// previous one is still in progress is skipped.
+type Scheduler struct {
+	logger log.Logger
+	runs   metrics.Counter // labels: job, outcome
+
+	mutex   sync.Mutex
+	jobs    map[string]*Job
+	started bool
+	wg      sync.WaitGroup
+}
+
+// Option configures optional Scheduler behaviour.
+type Option func(*Scheduler)
+
+// WithMetrics counts job runs by outcome: success, error or skipped (labels: job, outcome).
+func WithMetrics(runs metrics.Counter) Option {
+	return func(s *Scheduler) {
+		s.runs = runs
+	}
+}
+
+// New creates an empty Scheduler.
+func New(logger log.Logger, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		logger: logger,
+		runs:   discard.NewCounter(),
+		jobs:   map[string]*Job{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Add registers a job. Jobs must be added before Start.
+func (s *Scheduler) Add(job *Job) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.started {
+		return fmt.Errorf("cannot add job %q: scheduler already started", job.Name)
+	}
+	if job.Run == nil {
+		return fmt.Errorf("job %q has no Run function", job.Name)
+	}
+	if _, exists := s.jobs[job.Name]; exists {
+		return fmt.Errorf("job %q already registered", job.Name)
+	}
+	job.trigger = make(chan struct{}, 1)
+	s.jobs[job.Name] = job
+	return nil
+}
+
+// Start runs every job on its own goroutine until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.started = true
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job *Job) {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+}
+
+// Wait blocks until every job loop has exited after the context passed to Start was cancelled.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// Trigger asks for the named job to run as soon as possible, outside its schedule.
+// It reports false if the job does not exist. Triggers arriving while a run is
+// already pending or in progress are coalesced into it.
+func (s *Scheduler) Trigger(name string) bool {
+	s.mutex.Lock()
+	job, ok := s.jobs[name]
+	s.mutex.Unlock()
+	if !ok {
+		return false
+	}
+
+	select {
+	case job.trigger <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// RunNow runs the named job synchronously and returns its error. It fails with
+// ErrRunning, without waiting, if the job is already running.
+func (s *Scheduler) RunNow(ctx context.Context, name string) error {
+	s.mutex.Lock()
+	job, ok := s.jobs[name]
+	s.mutex.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown job %q", name)
+	}
+	return s.run(ctx, job)
+}
+
+// loop runs job until ctx is cancelled.
+func (s *Scheduler) loop(ctx context.Context, job *Job) {
+	if job.RunOnStart {
+		s.run(ctx, job)
+	}
+
+	for {
+		var timer *time.Timer
+		var due <-chan time.Time
+		if job.Schedule != nil {
+			delay := s.nextDelay(job)
+			s.logger.Log("message", "job scheduled", "job", job.Name, "in", delay)
+			timer = time.NewTimer(delay)
+			due = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-due:
+		case <-job.trigger:
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+		s.run(ctx, job)
+	}
+}
+
+// nextDelay returns how long to wait before the next scheduled run of job.
+func (s *Scheduler) nextDelay(job *Job) time.Duration {
+	now := time.Now()
+	delay := job.Schedule.Next(now).Sub(now)
+	if job.MinDelay != nil {
+		delay = max(delay, job.MinDelay())
+	}
+	if job.Jitter > 0 {
+		delay += rand.N(job.Jitter)
+	}
+	return max(delay, 0)
+}
+
+// run executes job once, unless a previous run is still in progress.
+func (s *Scheduler) run(ctx context.Context, job *Job) error {
+	if !job.running.CompareAndSwap(false, true) {
+		s.logger.Log("Warning", "previous run still in progress, skipping", "job", job.Name)
+		s.runs.With("job", job.Name, "outcome", "skipped").Add(1)
+		return ErrRunning
+	}
+	defer job.running.Store(false)
+
+	begin := time.Now()
+	err := job.Run(ctx)
+	if err != nil {
+		s.logger.Log("Error", "job failed", "job", job.Name, "err", err, "took", time.Since(begin))
+		s.runs.With("job", job.Name, "outcome", "error").Add(1)
+		return err
+	}
+	s.logger.Log("message", "job completed", "job", job.Name, "took", time.Since(begin))
+	s.runs.With("job", job.Name, "outcome", "success").Add(1)
+	return nil
+}
@@ -0,0 +1,221 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/log"
+	"github.com/pavankalyan767/exchange-rate-service/scheduler"
+)
+
+// outcomeCounter counts job runs by their outcome label.
+type outcomeCounter struct {
+	mutex  *sync.Mutex
+	counts map[string]float64
+	labels []string
+}
+
+func newOutcomeCounter() *outcomeCounter {
+	return &outcomeCounter{mutex: &sync.Mutex{}, counts: map[string]float64{}}
+}
+
+func (c *outcomeCounter) With(labelValues ...string) metrics.Counter {
+	return &outcomeCounter{mutex: c.mutex, counts: c.counts, labels: append(append([]string{}, c.labels...), labelValues...)}
+}
+
+func (c *outcomeCounter) Add(delta float64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i := 0; i+1 < len(c.labels); i += 2 {
+		if c.labels[i] == "outcome" {
+			c.counts[c.labels[i+1]] += delta
+		}
+	}
+}
+
+func (c *outcomeCounter) count(outcome string) float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.counts[outcome]
+}
+
+// blockingJob returns a job whose runs block until release is closed, and
+// signals every run start on started.
+func blockingJob(name string) (job *scheduler.Job, started chan struct{}, release chan struct{}, runs *atomic.Int32) {
+	started = make(chan struct{}, 10)
+	release = make(chan struct{})
+	runs = &atomic.Int32{}
+	job = &scheduler.Job{
+		Name: name,
+		Run: func(ctx context.Context) error {
+			runs.Add(1)
+			started <- struct{}{}
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+			return nil
+		},
+	}
+	return job, started, release, runs
+}
+
+func waitFor(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestScheduler_RunNowFailsWhileRunning(t *testing.T) {
+	s := scheduler.New(log.NewNopLogger())
+	job, started, release, _ := blockingJob("fetch")
+	if err := s.Add(job); err != nil {
+		t.Fatalf("expected no error adding the job, got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.RunNow(context.Background(), "fetch") }()
+	waitFor(t, started, "the first run")
+
+	if err := s.RunNow(context.Background(), "fetch"); !errors.Is(err, scheduler.ErrRunning) {
+		t.Errorf("expected ErrRunning, got %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("expected the first run to succeed, got %v", err)
+	}
+}
+
+func TestScheduler_SkipsOverlappingRun(t *testing.T) {
+	runs := newOutcomeCounter()
+	s := scheduler.New(log.NewNopLogger(), scheduler.WithMetrics(runs))
+	job, started, release, count := blockingJob("fetch")
+	s.Add(job)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		s.Wait()
+	}()
+	s.Start(ctx)
+
+	go s.RunNow(ctx, "fetch")
+	waitFor(t, started, "the manual run")
+
+	// The triggered run comes due while the manual one is still in progress.
+	s.Trigger("fetch")
+	deadline := time.Now().Add(2 * time.Second)
+	for runs.count("skipped") == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if skipped := runs.count("skipped"); skipped != 1 {
+		t.Errorf("expected 1 skipped run, got %v", skipped)
+	}
+	if n := count.Load(); n != 1 {
+		t.Errorf("expected the job to run once, got %d", n)
+	}
+	close(release)
+}
+
+func TestScheduler_CoalescesTriggers(t *testing.T) {
+	s := scheduler.New(log.NewNopLogger())
+	job, started, release, count := blockingJob("fetch")
+	close(release)
+	s.Add(job)
+
+	// Triggers arriving before the job gets to run make a single run.
+	for range 3 {
+		if !s.Trigger("fetch") {
+			t.Fatal("expected the job to exist")
+		}
+	}
+	if s.Trigger("missing") {
+		t.Error("expected no trigger for an unknown job")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	waitFor(t, started, "the triggered run")
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	s.Wait()
+
+	if n := count.Load(); n != 1 {
+		t.Errorf("expected 1 run for 3 triggers, got %d", n)
+	}
+}
+
+func TestScheduler_DelaysRunsByMinDelayAndJitter(t *testing.T) {
+	const minDelay, jitter = 40 * time.Millisecond, 20 * time.Millisecond
+
+	var mutex sync.Mutex
+	var starts, ends []time.Time
+	s := scheduler.New(log.NewNopLogger())
+	s.Add(&scheduler.Job{
+		Name:       "fetch",
+		Schedule:   scheduler.Every(time.Millisecond),
+		MinDelay:   func() time.Duration { return minDelay },
+		Jitter:     jitter,
+		RunOnStart: true,
+		Run: func(ctx context.Context) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			starts = append(starts, time.Now())
+			ends = append(ends, time.Now())
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+	s.Wait()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(starts) < 3 {
+		t.Fatalf("expected at least 3 runs, got %d", len(starts))
+	}
+	for i := 1; i < len(starts); i++ {
+		// The schedule alone would run the job every millisecond; MinDelay holds
+		// it back, and jitter adds at most its own length (plus timer slack).
+		gap := starts[i].Sub(ends[i-1])
+		if gap < minDelay || gap > minDelay+jitter+50*time.Millisecond {
+			t.Errorf("expected run %d to start between %s and %s after the previous one, got %s", i, minDelay, minDelay+jitter, gap)
+		}
+	}
+}
+
+func TestScheduler_WaitReturnsAfterCancel(t *testing.T) {
+	s := scheduler.New(log.NewNopLogger())
+	s.Add(&scheduler.Job{
+		Name:     "fetch",
+		Schedule: scheduler.Every(time.Hour),
+		Run:      func(ctx context.Context) error { return nil },
+	})
+	job, started, _, _ := blockingJob("slow")
+	job.RunOnStart = true
+	s.Add(job)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	waitFor(t, started, "the slow run")
+	cancel()
+
+	// Both the idle loop and the running job return on cancellation.
+	done := make(chan struct{})
+	go func() {
+		s.Wait()
+		close(done)
+	}()
+	waitFor(t, done, "Wait to return")
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pavankalyan767/exchange-rate-service/client"
+	"github.com/pavankalyan767/exchange-rate-service/provider"
+)
+
+// Fetch modes select how the rate fetcher combines its providers.
+const (
+	// FetchModeFailover uses the first provider, in priority order, that answers.
+	FetchModeFailover = "failover"
+	// FetchModeConsensus queries every provider concurrently and stores the median
+	// of the quotes that agree within the configured deviation.
+	FetchModeConsensus = "consensus"
+)
+
+// providerResult is the successful answer of a single provider.
+type providerResult[V any] struct {
+	name  string
+	rates map[string]V
+}
+
+// queryAll asks every available provider concurrently and returns the successful,
+// non-empty answers in priority order. Failures are logged and left out.
+func queryAll[V any](ctx context.Context, rf *RateFetcher, kind string, providers []provider.RateProvider, fetch func(context.Context, provider.RateProvider) (map[string]V, error)) []providerResult[V] {
+	results := make([]*providerResult[V], len(providers))
+
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		if !provider.IsAvailable(p) {
+			rf.logger.Log("Warning", "provider circuit breaker is open, excluding it from consensus", "kind", kind, "provider", p.Name())
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, p provider.RateProvider) {
+			defer wg.Done()
+
+			attemptCtx, cancel := context.WithTimeout(ctx, rf.providerTimeout)
+			defer cancel()
+
+			rates, err := fetch(attemptCtx, p)
+			if err != nil {
+				rf.logger.Log("Warning", "provider failed, excluding it from consensus", "kind", kind, "provider", p.Name(), "err", client.RedactError(err))
+				return
+			}
+			if len(rates) == 0 {
+				rf.logger.Log("Warning", "provider returned no rates, excluding it from consensus", "kind", kind, "provider", p.Name())
+				return
+			}
+			results[i] = &providerResult[V]{name: p.Name(), rates: rates}
+		}(i, p)
+	}
+	wg.Wait()
+
+	var answered []providerResult[V]
+	for _, result := range results {
+		if result != nil {
+			answered = append(answered, *result)
+		}
+	}
+	return answered
+}
+
+// consensusRates combines one rate map per provider, in priority order, into a
+// single map holding, for each currency pair, the median of the quotes that lie
+// within maxDeviation of the overall median. When none does, the quote of the
+// first provider counts as the only agreeing one. Pairs quoted by fewer than
+// quorum agreeing providers are dropped. It also returns the providers that
+// agreed on at least one stored pair. The relative deviation of every provider
+// from the median is reported on the spread gauge.
+func (rf *RateFetcher) consensusRates(kind string, results []providerResult[float64]) (map[string]float64, map[string]struct{}) {
+	quotes := make(map[string][]namedQuote)
+	for _, result := range results {
+		for pair, rate := range result.rates {
+			if rate <= 0 {
+				continue
+			}
+			quotes[pair] = append(quotes[pair], namedQuote{provider: result.name, rate: rate})
+		}
+	}
+
+	consensus := make(map[string]float64, len(quotes))
+	agreed := make(map[string]struct{})
+	for pair, pairQuotes := range quotes {
+		all := make([]float64, len(pairQuotes))
+		for i, quote := range pairQuotes {
+			all[i] = quote.rate
+		}
+		center := median(all)
+
+		var agreeing []namedQuote
+		var outliers []namedQuote
+		for _, quote := range pairQuotes {
+			deviation := math.Abs(quote.rate-center) / center
+			rf.consensusSpread.With("kind", kind, "provider", quote.provider, "pair", pair).Set(deviation)
+
+			if deviation > rf.maxDeviation {
+				outliers = append(outliers, quote)
+				continue
+			}
+			agreeing = append(agreeing, quote)
+		}
+		if len(agreeing) == 0 {
+			// No quote is near the median, as when exactly two providers disagree:
+			// without a majority the outlier cannot be told, so the quote of the
+			// provider first in priority order is kept.
+			primary := outliers[0]
+			rf.logger.Log("Warning", "providers disagree, keeping the primary provider's quote", "kind", kind, "provider", primary.provider, "pair", pair, "rate", primary.rate, "median", center)
+			agreeing = append(agreeing, primary)
+			outliers = outliers[1:]
+		}
+		for _, quote := range outliers {
+			rf.consensusOutliers.With("kind", kind, "provider", quote.provider).Add(1)
+			rf.logger.Log("Warning", "discarding outlier quote", "kind", kind, "provider", quote.provider, "pair", pair, "rate", quote.rate, "median", center)
+		}
+
+		if len(agreeing) < rf.quorum {
+			rf.logger.Log("Warning", "not enough agreeing providers, dropping pair", "kind", kind, "pair", pair, "agreeing", len(agreeing), "quorum", rf.quorum)
+			continue
+		}
+		rates := make([]float64, len(agreeing))
+		for i, quote := range agreeing {
+			rates[i] = quote.rate
+			agreed[quote.provider] = struct{}{}
+		}
+		consensus[pair] = median(rates)
+	}
+	return consensus, agreed
+}
+
+type namedQuote struct {
+	provider string
+	rate     float64
+}
+
+// median returns the median of values, which must not be empty.
+func median(values []float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+	return (sorted[mid-1] + sorted[mid]) / 2
+}
+
+// consensusSource names the providers, out of those that answered, that agreed
+// on a consensus result, in priority order. Providers whose every quote was
+// discarded as an outlier are left out.
+func consensusSource[V any](results []providerResult[V], agreed map[string]struct{}) string {
+	var names []string
+	for _, result := range results {
+		if _, ok := agreed[result.name]; ok {
+			names = append(names, result.name)
+		}
+	}
+	return FetchModeConsensus + ":" + strings.Join(names, ",")
+}
+
+// fetchLive returns one day's rate map and its source, using the configured fetch mode.
+func (rf *RateFetcher) fetchLive(ctx context.Context, kind string, providers []provider.RateProvider, currencies map[string]struct{}) (map[string]float64, string, error) {
+	fetch := func(ctx context.Context, p provider.RateProvider) (map[string]float64, error) {
+		return p.LiveRates(ctx, currencies)
+	}
+
+	if rf.mode != FetchModeConsensus {
+		return failover(ctx, rf, kind, providers, fetch)
+	}
+
+	results := queryAll(ctx, rf, kind, providers, fetch)
+	if len(results) < rf.quorum {
+		return nil, "", fmt.Errorf("only %d of %d %s providers answered, quorum is %d", len(results), len(providers), kind, rf.quorum)
+	}
+	rates, agreed := rf.consensusRates(kind, results)
+	if len(rates) == 0 {
+		return nil, "", fmt.Errorf("no %s rates reached consensus", kind)
+	}
+	return rates, consensusSource(results, agreed), nil
+}
+
+// fetchHistory returns the rate maps for a date range and their source, using the configured fetch mode.
+func (rf *RateFetcher) fetchHistory(ctx context.Context, kind string, providers []provider.RateProvider, fetch func(context.Context, provider.RateProvider) (map[string]map[string]float64, error)) (map[string]map[string]float64, string, error) {
+	if rf.mode != FetchModeConsensus {
+		return failover(ctx, rf, kind, providers, fetch)
+	}
+
+	results := queryAll(ctx, rf, kind, providers, fetch)
+	if len(results) < rf.quorum {
+		return nil, "", fmt.Errorf("only %d of %d %s providers answered, quorum is %d", len(results), len(providers), kind, rf.quorum)
+	}
+
+	// Regroup the answers by date and reach consensus for each day separately.
+	byDate := make(map[string][]providerResult[float64])
+	for _, result := range results {
+		for date, rates := range result.rates {
+			byDate[date] = append(byDate[date], providerResult[float64]{name: result.name, rates: rates})
+		}
+	}
+
+	history := make(map[string]map[string]float64, len(byDate))
+	agreed := make(map[string]struct{})
+	for date, dayResults := range byDate {
+		rates, dayAgreed := rf.consensusRates(kind, dayResults)
+		if len(rates) > 0 {
+			history[date] = rates
+			maps.Copy(agreed, dayAgreed)
+		}
+	}
+	if len(history) == 0 {
+		return nil, "", fmt.Errorf("no historical %s rates reached consensus", kind)
+	}
+	return history, consensusSource(results, agreed), nil
+}
@@ -8,22 +8,31 @@ import (
 	"github.com/pavankalyan767/exchange-rate-service/types"
 )
 
-func (s *ExchangeRateServiceImpl) Convert(ctx context.Context, req *types.ConvertRequest) (float64, error) {
+func (s *ExchangeRateServiceImpl) Convert(ctx context.Context, req *types.ConvertRequest) (float64, types.Freshness, error) {
 	// Validate the input currencies and amount
 	if !internal.IsAllowedCurrency(req.BaseCurrency) || !internal.IsAllowedCurrency(req.TargetCurrency) {
-		return 0, fmt.Errorf("invalid currency: %s or %s", req.BaseCurrency, req.TargetCurrency)
+		return 0, types.Freshness{}, fmt.Errorf("invalid currency: %s or %s", req.BaseCurrency, req.TargetCurrency)
 	}
 	if req.Amount <= 0 {
-		return 0, fmt.Errorf("invalid amount: %f", req.Amount)
+		return 0, types.Freshness{}, fmt.Errorf("invalid amount: %f", req.Amount)
+	}
+
+	at, err := parseInstant(req.At, req.Date)
+	if err != nil {
+		return 0, types.Freshness{}, err
 	}
 
 	// Fetch the rate using the unified helper function
-	rate, err := s.getRateForCurrencies(req.BaseCurrency, req.TargetCurrency,req.Date)
+	var rate float64
+	freshness, err := s.resolveAt(at, func(l *rateLookup) (err error) {
+		rate, err = l.getRateForCurrencies(req.BaseCurrency, req.TargetCurrency, req.Date)
+		return err
+	})
 	if err != nil {
-		return 0, fmt.Errorf("could not fetch rate: %v", err)
+		return 0, types.Freshness{}, fmt.Errorf("could not fetch rate: %v", err)
 	}
 
 	convertedAmount := req.Amount * rate
 
-	return convertedAmount, nil
+	return convertedAmount, freshness, nil
 }
\ No newline at end of file
@@ -13,10 +13,12 @@ import (
 	"github.com/pavankalyan767/exchange-rate-service/types"
 )
 
-func setupServiceWithMockRates() *service.ExchangeRateServiceImpl {
+func setupServiceWithMockRates(t *testing.T) *service.ExchangeRateServiceImpl {
 	logger := log.NewLogfmtLogger(os.Stderr)
-	fiatCache := cache.NewCache(1*time.Minute, 10*time.Second, logger)
-	cryptoCache := cache.NewCache(1*time.Minute, 10*time.Second, logger)
+	fiatCache := cache.NewRateCache(1*time.Minute, 10*time.Second, logger)
+	cryptoCache := cache.NewRateCache(1*time.Minute, 10*time.Second, logger)
+	t.Cleanup(fiatCache.Close)
+	t.Cleanup(cryptoCache.Close)
 
 	today := time.Now().Format(internal.DateFormat)
 	yesterday := time.Now().AddDate(0, 0, -1).Format(internal.DateFormat)
@@ -46,7 +48,7 @@ func setupServiceWithMockRates() *service.ExchangeRateServiceImpl {
 
 
 func TestConvert_ValidFiatToFiat(t *testing.T) {
-	svc := setupServiceWithMockRates()
+	svc := setupServiceWithMockRates(t)
 
 	req := &types.ConvertRequest{
 		BaseCurrency:   "USD",
@@ -55,7 +57,7 @@ func TestConvert_ValidFiatToFiat(t *testing.T) {
 		Date:           time.Now().Format(internal.DateFormat),
 	}
 
-	result, err := svc.Convert(context.Background(), req)
+	result, _, err := svc.Convert(context.Background(), req)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -68,7 +70,7 @@ func TestConvert_ValidFiatToFiat(t *testing.T) {
 
 
 func TestConvert_InvalidCurrency(t *testing.T) {
-	svc := setupServiceWithMockRates()
+	svc := setupServiceWithMockRates(t)
 
 	req := &types.ConvertRequest{
 		BaseCurrency:   "XXX",
@@ -77,7 +79,7 @@ func TestConvert_InvalidCurrency(t *testing.T) {
 		Date:           time.Now().Format(internal.DateFormat),
 	}
 
-	_, err := svc.Convert(context.Background(), req)
+	_, _, err := svc.Convert(context.Background(), req)
 	if err == nil {
 		t.Fatalf("expected error for invalid currency")
 	}
@@ -86,7 +88,7 @@ func TestConvert_InvalidCurrency(t *testing.T) {
 
 
 func TestFetchRate_ValidCryptoToCrypto(t *testing.T) {
-	svc := setupServiceWithMockRates()
+	svc := setupServiceWithMockRates(t)
 
 	req := &types.FetchRateRequest{
 		BaseCurrency:   "BTC",
@@ -94,7 +96,7 @@ func TestFetchRate_ValidCryptoToCrypto(t *testing.T) {
 		Date:           time.Now().Format(internal.DateFormat),
 	}
 
-	rate, err := svc.FetchRate(context.Background(), req)
+	rate, _, err := svc.FetchRate(context.Background(), req)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -108,7 +110,7 @@ func TestFetchRate_ValidCryptoToCrypto(t *testing.T) {
 
 
 func TestHistory_ValidRange(t *testing.T) {
-	svc := setupServiceWithMockRates()
+	svc := setupServiceWithMockRates(t)
 
 	today := time.Now().Format(internal.DateFormat)
 	yesterday := time.Now().AddDate(0, 0, -1).Format(internal.DateFormat)
@@ -120,7 +122,7 @@ func TestHistory_ValidRange(t *testing.T) {
 		To:             today,
 	}
 
-	rates, err := svc.History(context.Background(), req)
+	rates, _, err := svc.History(context.Background(), req)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -132,7 +134,7 @@ func TestHistory_ValidRange(t *testing.T) {
 
 
 func TestHistory_InvalidRange(t *testing.T) {
-	svc := setupServiceWithMockRates()
+	svc := setupServiceWithMockRates(t)
 
 	tomorrow := time.Now().AddDate(0, 0, 1).Format(internal.DateFormat)
 
@@ -143,7 +145,7 @@ func TestHistory_InvalidRange(t *testing.T) {
 		To:             tomorrow,
 	}
 
-	_, err := svc.History(context.Background(), req)
+	_, _, err := svc.History(context.Background(), req)
 	if err == nil {
 		t.Fatalf("expected error for missing rate data in future")
 	}
@@ -151,3 +153,55 @@ func TestHistory_InvalidRange(t *testing.T) {
 
 
 
+func TestFetchRate_ServesStaleRatesAndRequestsRefresh(t *testing.T) {
+	fiatCache := cache.NewRateCache(time.Minute, time.Minute, log.NewNopLogger(), cache.WithMaxStale(time.Hour))
+	cryptoCache := cache.NewRateCache(time.Minute, time.Minute, log.NewNopLogger())
+	t.Cleanup(fiatCache.Close)
+	t.Cleanup(cryptoCache.Close)
+
+	today := time.Now().Format(internal.DateFormat)
+	fiatCache.SetAt(today, types.RateTable{"USDINR": 83.0}, -time.Second, time.Now().Add(-10*time.Minute))
+
+	var refreshed []string
+	svc := service.NewExchangeRateServiceImpl(fiatCache, cryptoCache,
+		service.WithStaleRefresh(func(kind string) { refreshed = append(refreshed, kind) }, time.Minute))
+
+	for range 2 {
+		rate, freshness, err := svc.FetchRate(context.Background(), &types.FetchRateRequest{BaseCurrency: "USD", TargetCurrency: "INR"})
+		if err != nil || rate != 83.0 {
+			t.Fatalf("expected the stale rate 83.00, got %.2f (err %v)", rate, err)
+		}
+		if !freshness.Stale || freshness.Age < 10*time.Minute {
+			t.Errorf("expected a stale rate at least 10m old, got %+v", freshness)
+		}
+	}
+	if len(refreshed) != 1 || refreshed[0] != service.FiatRates {
+		t.Errorf("expected a single fiat refresh within the interval, got %v", refreshed)
+	}
+}
+
+func TestFetchRate_PastDayIsNeverStale(t *testing.T) {
+	fiatCache := cache.NewRateCache(time.Minute, time.Minute, log.NewNopLogger(), cache.WithMaxStale(time.Hour))
+	cryptoCache := cache.NewRateCache(time.Minute, time.Minute, log.NewNopLogger())
+	t.Cleanup(fiatCache.Close)
+	t.Cleanup(cryptoCache.Close)
+
+	// A past day reloaded from the store a while ago, past its TTL.
+	yesterday := time.Now().AddDate(0, 0, -1).Format(internal.DateFormat)
+	fiatCache.SetAt(yesterday, types.RateTable{"USDINR": 83.0}, -time.Second, time.Now().Add(-30*time.Hour))
+
+	var refreshed []string
+	svc := service.NewExchangeRateServiceImpl(fiatCache, cryptoCache,
+		service.WithStaleRefresh(func(kind string) { refreshed = append(refreshed, kind) }, time.Minute))
+
+	rate, freshness, err := svc.FetchRate(context.Background(), &types.FetchRateRequest{BaseCurrency: "USD", TargetCurrency: "INR", Date: yesterday})
+	if err != nil || rate != 83.0 {
+		t.Fatalf("expected USDINR 83.00 on %s, got %.2f (err %v)", yesterday, rate, err)
+	}
+	if freshness.Stale {
+		t.Errorf("expected the settled rate of %s not to be stale, got %+v", yesterday, freshness)
+	}
+	if len(refreshed) != 0 {
+		t.Errorf("expected no live refresh for a past day, got %v", refreshed)
+	}
+}
@@ -10,17 +10,26 @@ import (
 
 
 
-func (s *ExchangeRateServiceImpl) FetchRate(ctx context.Context, req *types.FetchRateRequest) (output float64, err error) {
+func (s *ExchangeRateServiceImpl) FetchRate(ctx context.Context, req *types.FetchRateRequest) (output float64, freshness types.Freshness, err error) {
 	// Validate the input currencies
 	if !internal.IsAllowedCurrency(req.BaseCurrency) || !internal.IsAllowedCurrency(req.TargetCurrency) {
-		return 0, fmt.Errorf("invalid currency: %s or %s", req.BaseCurrency, req.TargetCurrency)
+		return 0, freshness, fmt.Errorf("invalid currency: %s or %s", req.BaseCurrency, req.TargetCurrency)
+	}
+
+	at, err := parseInstant(req.At, req.Date)
+	if err != nil {
+		return 0, freshness, err
 	}
 
 	// Use a single helper function to get the rate for any currency pair.
-	rate, err := s.getRateForCurrencies(req.BaseCurrency, req.TargetCurrency,req.Date)
+	var rate float64
+	freshness, err = s.resolveAt(at, func(l *rateLookup) (err error) {
+		rate, err = l.getRateForCurrencies(req.BaseCurrency, req.TargetCurrency, req.Date)
+		return err
+	})
 	if err != nil {
-		return 0, fmt.Errorf("could not fetch rate: %v", err)
+		return 0, types.Freshness{}, fmt.Errorf("could not fetch rate: %v", err)
 	}
 
-	return rate,nil
+	return rate,freshness,nil
 }
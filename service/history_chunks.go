@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pavankalyan767/exchange-rate-service/internal"
+	"github.com/pavankalyan767/exchange-rate-service/provider"
+)
+
+// historyChunk is the answer to the historical request for one part of a date range.
+type historyChunk struct {
+	start, end time.Time
+	rates      map[string]map[string]float64
+	source     string
+}
+
+// chunkDays returns the number of days a single historical request may span:
+// the configured chunk size, lowered to the smallest limit of any of providers.
+func (rf *RateFetcher) chunkDays(providers []provider.RateProvider) int {
+	days := rf.historyChunkDays
+	for _, p := range providers {
+		if limited, ok := p.(provider.HistoryLimited); ok {
+			if limit := limited.MaxHistoryDays(); limit > 0 {
+				days = min(days, limit)
+			}
+		}
+	}
+	return max(days, 1)
+}
+
+// splitRange cuts the days from start to end, both included, into consecutive
+// ranges of at most days days.
+func splitRange(start, end time.Time, days int) [][2]time.Time {
+	var ranges [][2]time.Time
+	for from := start; !from.After(end); {
+		to := from.AddDate(0, 0, days-1)
+		if to.After(end) {
+			to = end
+		}
+		ranges = append(ranges, [2]time.Time{from, to})
+		from = to.AddDate(0, 0, 1)
+	}
+	return ranges
+}
+
+// fetchHistoryChunked fetches the rates of currencies from start to end,
+// both included, in provider-sized chunks. At most historyConcurrency chunks are
+// in flight at once, and each one goes through failover or consensus on its
+// own, so a failed chunk does not discard the others: the chunks that succeeded
+// are returned together with the errors of those that did not.
+func (rf *RateFetcher) fetchHistoryChunked(ctx context.Context, kind string, providers []provider.RateProvider, start, end time.Time, currencies map[string]struct{}) ([]historyChunk, error) {
+	ranges := splitRange(start, end, rf.chunkDays(providers))
+	chunks := make([]*historyChunk, len(ranges))
+	errs := make([]error, len(ranges))
+
+	sem := make(chan struct{}, rf.historyConcurrency)
+	var wg sync.WaitGroup
+	for i, r := range ranges {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, from, to time.Time) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			rates, source, err := rf.fetchHistory(ctx, kind, providers, func(ctx context.Context, p provider.RateProvider) (map[string]map[string]float64, error) {
+				return p.HistoricalRates(ctx, from, to, currencies)
+			})
+			if err != nil {
+				errs[i] = fmt.Errorf("%s to %s: %w", from.Format(internal.DateFormat), to.Format(internal.DateFormat), err)
+				return
+			}
+			chunks[i] = &historyChunk{start: from, end: to, rates: rates, source: source}
+		}(i, r[0], r[1])
+	}
+	wg.Wait()
+
+	var fetched []historyChunk
+	for _, chunk := range chunks {
+		if chunk != nil {
+			fetched = append(fetched, *chunk)
+		}
+	}
+	if len(fetched) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fetched, fmt.Errorf("%d of %d history chunks failed: %w", len(ranges)-len(fetched), len(ranges), err)
+	}
+	return fetched, nil
+}
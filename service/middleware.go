@@ -25,44 +25,54 @@ func NewLoggingMiddleware(logger log.Logger, next ExchangeRateService) ExchangeR
 
 // FetchFiatRate implements the ExchangeRateService interface.
 // It logs the call and delegates to the next service.
-func (mw *loggingMiddleware) FetchRate(ctx context.Context, req *types.FetchRateRequest) (output float64, err error) {
+func (mw *loggingMiddleware) FetchRate(ctx context.Context, req *types.FetchRateRequest) (output float64, freshness types.Freshness, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "fetch_fiat_rate",
 			"input_base", req.BaseCurrency,
 			"input_target", req.TargetCurrency,
+			"input_at", req.At,
 			"output_rate", output,
+			"output_age", freshness.Age,
+			"output_stale", freshness.Stale,
+			"output_version", freshness.Version,
+			"output_observed_at", freshness.ObservedAt,
 			"err", err,
 			"took", time.Since(begin),
 		)
 	}(time.Now())
 
 	// Call the next service in the chain.
-	output, err = mw.next.FetchRate(ctx, req)
+	output, freshness, err = mw.next.FetchRate(ctx, req)
 	return
 }
 
 // Convert implements the ExchangeRateService interface.
 // It logs the call and delegates to the next service.
-func (mw *loggingMiddleware) Convert(ctx context.Context, req *types.ConvertRequest) (output float64, err error) {
+func (mw *loggingMiddleware) Convert(ctx context.Context, req *types.ConvertRequest) (output float64, freshness types.Freshness, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "convert",
 			"BaseCurrency", req.BaseCurrency,
 			"TargetCurrency", req.TargetCurrency,
 			"input_amount", req.Amount,
+			"input_at", req.At,
 			"output_rate", output,
+			"output_age", freshness.Age,
+			"output_stale", freshness.Stale,
+			"output_version", freshness.Version,
+			"output_observed_at", freshness.ObservedAt,
 			"err", err,
 			"took", time.Since(begin),
 		)
 	}(time.Now())
-	output, err = mw.next.Convert(ctx, req)
+	output, freshness, err = mw.next.Convert(ctx, req)
 	return
 }
 
 // History implements the ExchangeRateService interface.
 // The return type is now float64, so we've updated the logic to match.
-func (mw *loggingMiddleware) History(ctx context.Context, req *types.HistoryRequest) (output map[string]float64, err error) {
+func (mw *loggingMiddleware) History(ctx context.Context, req *types.HistoryRequest) (output map[string]float64, freshness types.Freshness, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "history",
@@ -71,11 +81,14 @@ func (mw *loggingMiddleware) History(ctx context.Context, req *types.HistoryRequ
 			"input_from", req.From,
 			"input_to", req.To,
 			"output", output,
+			"output_age", freshness.Age,
+			"output_stale", freshness.Stale,
+			"output_version", freshness.Version,
 			"err", err,
 			"took", time.Since(begin),
 		)
 	}(time.Now())
-	output, err = mw.next.History(ctx, req)
+	output, freshness, err = mw.next.History(ctx, req)
 	return
 }
 
@@ -96,7 +109,7 @@ func NewInstrumentingMiddleware(requestCount metrics.Counter, requestLatency met
 
 	}
 }
-func (mw *instrumentingMiddleware) FetchRate(ctx context.Context, req *types.FetchRateRequest) (output float64, err error) {
+func (mw *instrumentingMiddleware) FetchRate(ctx context.Context, req *types.FetchRateRequest) (output float64, freshness types.Freshness, err error) {
 
 	defer func(begin time.Time) {
 		lvs := []string{"method", "FetchRate", "error", fmt.Sprint(err != nil)}
@@ -104,32 +117,32 @@ func (mw *instrumentingMiddleware) FetchRate(ctx context.Context, req *types.Fet
 		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	output, err = mw.next.FetchRate(ctx,req)
+	output, freshness, err = mw.next.FetchRate(ctx,req)
 	return
 }
 
 // Convert implements the ExchangeRateService interface.
 // It logs the call and delegates to the next service.
-func (mw *instrumentingMiddleware) Convert(ctx context.Context, req *types.ConvertRequest) (output float64, err error) {
+func (mw *instrumentingMiddleware) Convert(ctx context.Context, req *types.ConvertRequest) (output float64, freshness types.Freshness, err error) {
 	defer func(begin time.Time) {
 		lvs := []string{"method", "Convert", "error", fmt.Sprint(err != nil)}
 		mw.requestCount.With(lvs...).Add(1)
 		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	output, err = mw.next.Convert(ctx,req)
+	output, freshness, err = mw.next.Convert(ctx,req)
 	return
 }
 
 // History implements the ExchangeRateService interface.
 // The return type is now float64, so we've updated the logic to match.
-func (mw *instrumentingMiddleware) History(ctx context.Context, req *types.HistoryRequest) (output map[string]float64, err error) {
+func (mw *instrumentingMiddleware) History(ctx context.Context, req *types.HistoryRequest) (output map[string]float64, freshness types.Freshness, err error) {
 	defer func(begin time.Time) {
 		lvs := []string{"method", "History", "error", fmt.Sprint(err != nil)}
 		mw.requestCount.With(lvs...).Add(1)
 		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	output, err = mw.next.History(ctx,req)
+	output, freshness, err = mw.next.History(ctx,req)
 	return
 }
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pavankalyan767/exchange-rate-service/internal"
+	"github.com/pavankalyan767/exchange-rate-service/types"
+)
+
+// parseInstant parses the RFC 3339 instant a request asks rates at. It returns
+// the zero time when the request gives none, and asks by date or for today.
+func parseInstant(at, date string) (time.Time, error) {
+	if at == "" {
+		return time.Time{}, nil
+	}
+	if date != "" {
+		return time.Time{}, errors.New("date and at cannot both be given")
+	}
+	instant, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid instant %q, expected RFC 3339: %w", at, err)
+	}
+	if instant.After(time.Now()) {
+		return time.Time{}, fmt.Errorf("instant %s is in the future", at)
+	}
+	return instant, nil
+}
+
+// observation is a rate map of one kind and the instant it was fetched at.
+type observation struct {
+	rates types.RateTable
+	at    time.Time
+}
+
+// rateAt returns the rate of pair from the observation of kind nearest at or
+// before l.at. The observation is read once per kind and lookup.
+func (l *rateLookup) rateAt(kind, pair string) (float64, bool) {
+	obs, ok := l.observations[kind]
+	if !ok {
+		obs = l.s.observationAt(kind, l.at)
+		if l.observations == nil {
+			l.observations = map[string]observation{}
+		}
+		l.observations[kind] = obs
+	}
+
+	rate, ok := obs.rates.Rate(pair)
+	if ok {
+		l.freshness.Add(time.Since(obs.at), false)
+		l.freshness.Observe(obs.at)
+	}
+	return rate, ok
+}
+
+// observationAt returns the rates of kind fetched nearest at or before at, on
+// at's day: the latest stored observation, unless the rate map of the day was
+// fetched later but still not after at, as happens without a store or for days
+// fetched before observations were kept. A day that is over but has neither,
+// typically one only fetched through the history timeframe after it ended,
+// falls back to its daily rate map, observed at the end of the day. The rates
+// are nil when nothing qualifies.
+func (s *ExchangeRateServiceImpl) observationAt(kind string, at time.Time) observation {
+	at = at.In(time.Local)
+	var obs observation
+	if s.store != nil {
+		record, ok, err := s.store.ObservationAt(kind, at)
+		if err == nil && ok {
+			obs = observation{rates: record.Rates, at: record.FetchedAt}
+		}
+	}
+
+	date := at.Format(internal.DateFormat)
+	item, ok := readThrough(s.cacheFor(kind), s.store, kind, date)
+	if !ok {
+		return obs
+	}
+	fetched := time.Now().Add(-item.Age)
+	dayEnd := time.Date(at.Year(), at.Month(), at.Day()+1, 0, 0, 0, 0, time.Local).Add(-time.Nanosecond)
+	switch {
+	case !fetched.After(at):
+		if fetched.After(obs.at) {
+			obs = observation{rates: item.Value, at: fetched}
+		}
+	case obs.rates == nil && dayEnd.Before(time.Now()):
+		obs = observation{rates: item.Value, at: dayEnd}
+	}
+	return obs
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pavankalyan767/exchange-rate-service/store"
+	"github.com/pavankalyan767/exchange-rate-service/types"
+)
+
+// Publisher makes a batch of rate map updates visible at once, seqlock-style,
+// and numbers the published versions. Writers apply a whole batch under it;
+// readers do not lock but retry when a batch was published while they read,
+// so a response never mixes rates from before and after a refresh.
+//
+// The RateFetcher that writes and the ExchangeRateServiceImpl that reads must
+// share a Publisher; see WithPublisher and WithPublishedReads.
+type Publisher struct {
+	// seq is odd while a batch is being applied, and counts two per batch.
+	seq   atomic.Uint64
+	mutex sync.Mutex
+}
+
+// NewPublisher creates a Publisher at version 0.
+func NewPublisher() *Publisher {
+	return &Publisher{}
+}
+
+// Version returns the number of batches published so far. It starts at 0 on
+// every start of the service.
+func (p *Publisher) Version() uint64 {
+	return p.seq.Load() / 2
+}
+
+// publish applies a batch of updates as one version.
+func (p *Publisher) publish(apply func()) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.seq.Add(1)
+	defer p.seq.Add(1)
+	apply()
+}
+
+// read runs fn until it completes without a batch being published meanwhile,
+// and returns the version it read. While a batch is being applied it waits
+// for it to finish rather than spinning.
+func (p *Publisher) read(fn func()) uint64 {
+	for {
+		seq := p.seq.Load()
+		if seq%2 == 1 {
+			p.mutex.Lock()
+			p.mutex.Unlock()
+			continue
+		}
+		fn()
+		if p.seq.Load() == seq {
+			return seq / 2
+		}
+	}
+}
+
+// batch stages rate maps to be published together by RateFetcher.publish.
+type batch struct {
+	entries []stagedEntry
+}
+
+type stagedEntry struct {
+	store.Entry
+	ttl time.Duration
+}
+
+// save stages the rate map of kind for date, as fetched now from source.
+func (b *batch) save(kind, date string, rates types.RateTable, ttl time.Duration, source string) {
+	b.put(kind, date, store.Record{Rates: rates, Source: source, FetchedAt: time.Now()}, ttl)
+}
+
+// observe is like save for a live poll, which is also kept as an intraday
+// observation; see store.Entry.
+func (b *batch) observe(kind, date string, rates types.RateTable, ttl time.Duration, source string) {
+	b.save(kind, date, rates, ttl, source)
+	b.entries[len(b.entries)-1].Observed = true
+}
+
+// put stages a rate map together with its provenance.
+func (b *batch) put(kind, date string, record store.Record, ttl time.Duration) {
+	b.entries = append(b.entries, stagedEntry{Entry: store.Entry{Kind: kind, Date: date, Record: record}, ttl: ttl})
+}
+
+// publish persists the staged rate maps in one transaction, when a store is
+// configured, and then caches them as one version. A failed write is only
+// logged, as the rates are still served from memory. The store is written
+// before the version is opened, so readers never wait on a disk sync.
+func (rf *RateFetcher) publish(b *batch) {
+	if len(b.entries) == 0 {
+		return
+	}
+
+	if rf.store != nil {
+		entries := make([]store.Entry, len(b.entries))
+		for i, e := range b.entries {
+			entries[i] = e.Entry
+		}
+		if err := rf.store.PutAll(entries); err != nil {
+			rf.logger.Log("Error", "failed to persist rates", "entries", len(entries), "err", err)
+		}
+	}
+
+	rf.publisher.publish(func() {
+		for _, e := range b.entries {
+			rf.cacheFor(e.Kind).Set(e.Date, e.Record.Rates, e.ttl)
+			rf.recordSource(e.Kind, e.Date, e.Record.Source)
+		}
+	})
+}
@@ -2,157 +2,546 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
 
 	"time"
 
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/log"
 	"github.com/pavankalyan767/exchange-rate-service/cache"
 	"github.com/pavankalyan767/exchange-rate-service/client"
 	"github.com/pavankalyan767/exchange-rate-service/internal"
-	"github.com/go-kit/log"
+	"github.com/pavankalyan767/exchange-rate-service/provider"
+	"github.com/pavankalyan767/exchange-rate-service/store"
+	"github.com/pavankalyan767/exchange-rate-service/types"
+)
+
+// Rate kinds, used to tell the fiat and crypto rate maps apart.
+const (
+	FiatRates   = "fiat"
+	CryptoRates = "crypto"
 )
 
 type RateFetcher struct {
-	apiClient   *client.APIClient
-	fiatcache   *cache.Cache
-	cryptocache *cache.Cache
-	logger 		log.Logger
+	fiatProviders   []provider.RateProvider
+	cryptoProviders []provider.RateProvider
+	fiatcache       *cache.RateCache
+	cryptocache     *cache.RateCache
+	logger          log.Logger
+
+	providerTimeout time.Duration
+	// liveTTL is how long live rates stay fresh in the caches.
+	liveTTL time.Duration
+
+	// horizon is the number of days of history kept in the caches.
+	horizon int
+	// Historical ranges are fetched in chunks of at most historyChunkDays days,
+	// historyConcurrency at a time; see history_chunks.go.
+	historyChunkDays   int
+	historyConcurrency int
+
+	// Consensus mode settings, see consensus.go.
+	mode              string
+	maxDeviation      float64
+	quorum            int
+	consensusSpread   metrics.Gauge
+	consensusOutliers metrics.Counter
+
+	// coverage reports the share of the history window filled by Reconcile (labels: kind).
+	coverage metrics.Gauge
+	// lastUpdate reports when live rates were last stored (labels: kind).
+	lastUpdate metrics.Gauge
+
+	// store, if set, persists every rate map the fetcher caches.
+	store *store.Store
+	// publisher makes each batch of updates visible at once; see publish.go.
+	publisher *Publisher
+
+	// sources records which provider served each day's rate map, keyed by kind and date.
+	sourcesMu sync.RWMutex
+	sources   map[string]map[string]string
 }
 
-func NewRateFetcher(apiClient *client.APIClient, fiatcache *cache.Cache, cryptocache *cache.Cache,logger log.Logger) *RateFetcher {
-	return &RateFetcher{
-		apiClient:   apiClient,
-		fiatcache:   fiatcache,
-		cryptocache: cryptocache,
-		logger:		logger,
+// FetcherOption configures optional RateFetcher behaviour.
+type FetcherOption func(*RateFetcher)
+
+// WithProviderTimeout bounds each attempt against a single provider.
+// A provider that does not answer in time is skipped in favour of the next one.
+func WithProviderTimeout(timeout time.Duration) FetcherOption {
+	return func(rf *RateFetcher) {
+		rf.providerTimeout = timeout
 	}
 }
 
-type LiveRateAPIResponse struct {
-	Quotes map[string]float64 `json:"quotes"`
-	Result float64            `json:"result,omitempty"`
+// WithLiveTTL sets how long live rates are cached before they are considered
+// stale. It defaults to 24 hours.
+func WithLiveTTL(ttl time.Duration) FetcherOption {
+	return func(rf *RateFetcher) {
+		rf.liveTTL = ttl
+	}
 }
 
-type HistoricalRateAPIResponse struct {
-	Quotes map[string]map[string]float64 `json:"quotes"`
+// WithHistoryHorizon sets how many days of historical rates are fetched at
+// startup and kept afterwards. It defaults to internal.LookbackDays.
+func WithHistoryHorizon(days int) FetcherOption {
+	return func(rf *RateFetcher) {
+		rf.horizon = max(days, 1)
+	}
 }
 
-type CryptoRateAPIResponse struct {
-	Rates  map[string]float64 `json:"rates"`
-	Result float64            `json:"result,omitempty"`
+// WithHistoryChunks splits historical fetches into requests spanning at most
+// days days (further lowered to any provider's own limit), with up to
+// concurrency requests in flight at once.
+func WithHistoryChunks(days, concurrency int) FetcherOption {
+	return func(rf *RateFetcher) {
+		rf.historyChunkDays = max(days, 1)
+		rf.historyConcurrency = max(concurrency, 1)
+	}
 }
 
-func (rf *RateFetcher) LiveRate(ctx context.Context) error {
+// WithStore persists every fetched rate map to st, next to caching it, and lets
+// the fetcher resume from the history already stored after a restart.
+func WithStore(st *store.Store) FetcherOption {
+	return func(rf *RateFetcher) {
+		rf.store = st
+	}
+}
 
-	endpoint := "live"
-	baseCurrency := internal.BaseCurrency
+// WithPublisher publishes every batch of updates through p, which readers
+// share through WithPublishedReads. Without it the fetcher uses a Publisher
+// of its own.
+func WithPublisher(p *Publisher) FetcherOption {
+	return func(rf *RateFetcher) {
+		rf.publisher = p
+	}
+}
 
-	request_url := rf.apiClient.BuildLiveURL(endpoint, baseCurrency)
+// WithConsensus switches the fetcher to consensus mode: every provider is queried
+// concurrently, quotes deviating from the median by more than maxDeviation
+// (a fraction, e.g. 0.01 for 1%) are discarded, and the median of the remaining
+// quotes is stored. A pair needs at least quorum agreeing providers to be stored.
+func WithConsensus(maxDeviation float64, quorum int) FetcherOption {
+	return func(rf *RateFetcher) {
+		rf.mode = FetchModeConsensus
+		rf.maxDeviation = maxDeviation
+		rf.quorum = max(quorum, 1)
+	}
+}
 
-	resp, err := rf.apiClient.Get(ctx, request_url)
-	if err != nil {
-		return fmt.Errorf("error getting response from api client for live rates: %v", err)
+// WithConsensusMetrics reports each provider's relative deviation from the
+// consensus median (labels: kind, provider, pair) and the number of discarded
+// outlier quotes (labels: kind, provider).
+func WithConsensusMetrics(spread metrics.Gauge, outliers metrics.Counter) FetcherOption {
+	return func(rf *RateFetcher) {
+		rf.consensusSpread = spread
+		rf.consensusOutliers = outliers
 	}
+}
 
-	var liveRateResponse LiveRateAPIResponse
-	err = json.Unmarshal(resp, &liveRateResponse)
-	if err != nil {
-		return fmt.Errorf("error unmarshalling live rate response: %v", err)
+// WithCoverageMetric reports the percentage of the history window for which
+// every allowed pair has a rate, as measured by Reconcile (labels: kind).
+func WithCoverageMetric(coverage metrics.Gauge) FetcherOption {
+	return func(rf *RateFetcher) {
+		rf.coverage = coverage
+	}
+}
+
+// WithUpdateMetric reports the Unix time of the last successful live update of
+// each kind on lastUpdate (labels: kind). The provider is left out: in
+// consensus mode the source names every provider that agreed, and a series per
+// combination would keep reporting stale times.
+func WithUpdateMetric(lastUpdate metrics.Gauge) FetcherOption {
+	return func(rf *RateFetcher) {
+		rf.lastUpdate = lastUpdate
+	}
+}
+
+// NewRateFetcher creates a RateFetcher. Providers are tried in the order given.
+func NewRateFetcher(fiatProviders, cryptoProviders []provider.RateProvider, fiatcache *cache.RateCache, cryptocache *cache.RateCache, logger log.Logger, opts ...FetcherOption) *RateFetcher {
+	rf := &RateFetcher{
+		fiatProviders:   fiatProviders,
+		cryptoProviders: cryptoProviders,
+		fiatcache:       fiatcache,
+		cryptocache:     cryptocache,
+		logger:          logger,
+		providerTimeout: 10 * time.Second,
+		liveTTL:         24 * time.Hour,
+		horizon:         internal.LookbackDays,
+
+		historyChunkDays:   365,
+		historyConcurrency: 4,
+
+		mode:              FetchModeFailover,
+		quorum:            1,
+		consensusSpread:   discard.NewGauge(),
+		consensusOutliers: discard.NewCounter(),
+		coverage:          discard.NewGauge(),
+		lastUpdate:        discard.NewGauge(),
+		publisher:         NewPublisher(),
+		sources: map[string]map[string]string{
+			FiatRates:   {},
+			CryptoRates: {},
+		},
+	}
+	for _, opt := range opts {
+		opt(rf)
+	}
+	return rf
+}
+
+// Source returns the name of the provider that served the rate map of the given kind and date.
+func (rf *RateFetcher) Source(kind, date string) (string, bool) {
+	rf.sourcesMu.RLock()
+	name, ok := rf.sources[kind][date]
+	rf.sourcesMu.RUnlock()
+
+	if ok || rf.store == nil {
+		return name, ok
+	}
+	record, ok, err := rf.store.Get(kind, date)
+	if err != nil || !ok {
+		return "", false
+	}
+	return record.Source, true
+}
+
+// providersFor returns the providers of the rate maps of kind and the currencies
+// they are asked for.
+func (rf *RateFetcher) providersFor(kind string) ([]provider.RateProvider, map[string]struct{}) {
+	if kind == CryptoRates {
+		return rf.cryptoProviders, internal.CryptoCurrencies()
+	}
+	return rf.fiatProviders, internal.FiatCurrencies()
+}
+
+// cacheFor returns the cache holding the rate maps of kind.
+func (rf *RateFetcher) cacheFor(kind string) *cache.RateCache {
+	if kind == CryptoRates {
+		return rf.cryptocache
+	}
+	return rf.fiatcache
+}
+
+// saveLive publishes a live poll of kind as the rate map for date, on its own
+// (see publish), and keeps it as an intraday observation.
+func (rf *RateFetcher) saveLive(kind, date string, rates types.RateTable, source string) {
+	var b batch
+	b.observe(kind, date, rates, rf.liveTTL, source)
+	rf.publish(&b)
+}
+
+func (rf *RateFetcher) recordSource(kind, date, name string) {
+	rf.sourcesMu.Lock()
+	defer rf.sourcesMu.Unlock()
+
+	rf.sources[kind][date] = name
+}
+
+func (rf *RateFetcher) forgetSource(kind, date string) {
+	rf.sourcesMu.Lock()
+	defer rf.sourcesMu.Unlock()
+
+	delete(rf.sources[kind], date)
+}
+
+// PollInterval returns how long to wait before polling the providers of the given
+// kind again. It is base unless the quota of a provider that would be polled runs
+// out before it resets at that pace, in which case polling slows down to spread the
+// remaining requests evenly over the rest of the period. In failover mode only the
+// first provider with budget left is polled; in consensus mode all of them are.
+func (rf *RateFetcher) PollInterval(kind string, base time.Duration) time.Duration {
+	providers := rf.fiatProviders
+	if kind == CryptoRates {
+		providers = rf.cryptoProviders
+	}
+
+	interval := base
+	var nextReset time.Duration
+	polled := false
+	for _, p := range providers {
+		remaining, resetsIn, ok := 0, time.Duration(0), false
+		if b, isBudgeted := p.(provider.Budgeted); isBudgeted {
+			remaining, resetsIn, ok = b.Budget()
+		}
+		if ok && remaining == 0 {
+			if nextReset == 0 || resetsIn < nextReset {
+				nextReset = resetsIn
+			}
+			continue
+		}
+
+		polled = true
+		if ok {
+			interval = max(interval, resetsIn/time.Duration(remaining))
+		}
+		if rf.mode != FetchModeConsensus {
+			break
+		}
+	}
+
+	if !polled && nextReset > 0 {
+		// Every quota is used up: wait for the first one to reset.
+		interval = max(base, nextReset)
 	}
-	if len(liveRateResponse.Quotes) == 0 {
-		return errors.New("no quotes found in live rate response")
+	if interval != base {
+		rf.logger.Log("Warning", "upstream quota running low, polling less often", "kind", kind, "interval", interval)
+	}
+	return interval
+}
+
+// failover tries each provider in priority order and returns the first non-empty
+// result along with the name of the provider that served it. A provider is skipped
+// when its circuit breaker is open, and passed over when it returns an error,
+// exceeds the provider timeout, or returns no rates.
+func failover[V any](ctx context.Context, rf *RateFetcher, kind string, providers []provider.RateProvider, fetch func(context.Context, provider.RateProvider) (map[string]V, error)) (map[string]V, string, error) {
+	if len(providers) == 0 {
+		return nil, "", fmt.Errorf("no %s providers configured", kind)
 	}
 
-	exchangeRate := make(map[string]float64)
+	var errs []error
+	for _, p := range providers {
+		if !provider.IsAvailable(p) {
+			rf.logger.Log("Warning", "provider circuit breaker is open, skipping", "kind", kind, "provider", p.Name())
+			errs = append(errs, fmt.Errorf("%s: skipped, circuit breaker is open", p.Name()))
+			continue
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, rf.providerTimeout)
+		result, err := fetch(attemptCtx, p)
+		cancel()
 
-	currencies := internal.AllowedFiatCurrencies
+		if err == nil && len(result) == 0 {
+			err = errors.New("empty response")
+		}
+		if err != nil {
+			err = client.RedactError(err)
+			rf.logger.Log("Warning", "provider failed, trying next", "kind", kind, "provider", p.Name(), "err", err)
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		return result, p.Name(), nil
+	}
+
+	return nil, "", fmt.Errorf("all %s providers failed: %w", kind, errors.Join(errs...))
+}
+
+func (rf *RateFetcher) LiveRate(ctx context.Context) error {
+	baseCurrency := internal.BaseCurrency
+
+	currencies := internal.FiatCurrencies()
+	quotes, source, err := rf.fetchLive(ctx, FiatRates, rf.fiatProviders, currencies)
+	if err != nil {
+		return fmt.Errorf("error fetching live rates: %w", err)
+	}
+
+	exchangeRate := types.RateTable{}
 
 	for currency := range currencies {
 		if currency != baseCurrency {
 			exchangeRate[baseCurrency+currency] = 0
 		}
 	}
-	for key, value := range liveRateResponse.Quotes {
+	for key, value := range quotes {
 		exchangeRate[key] = value
 	}
 
 	today := time.Now().Format(internal.DateFormat)
 
 	// Cache the entire map of today's rates using the date as the key.
-	rf.fiatcache.Set(today, exchangeRate, 24*time.Hour)
-	rf.logger.Log("Live rates cached successfully")
+	rf.saveLive(FiatRates, today, exchangeRate, source)
+	rf.lastUpdate.With("kind", FiatRates).Set(float64(time.Now().Unix()))
+	rf.logger.Log("message", "Live rates cached successfully", "provider", source)
 
 	return nil
 
 }
 
+// HistoricalRate fetches the history window, from the horizon through today, for
+// fiat and, when crypto providers are configured, crypto rates. Days already
+// in the store are not fetched again.
 func (rf *RateFetcher) HistoricalRate(ctx context.Context) error {
 
-	startDate := time.Now().AddDate(0, 0, -1*internal.LookbackDays).Format(internal.DateFormat)
-	endDate := time.Now().AddDate(0, 0, 0).Format(internal.DateFormat)
-	request_url := rf.apiClient.BuildHistoryURL(startDate, endDate, internal.AllowedFiatCurrencies)
-
-	resp, err := rf.apiClient.Get(ctx, request_url)
-	if err != nil {
-		return fmt.Errorf("error getting response from api client for historical rates %w", err)
+	startDate := time.Now().AddDate(0, 0, -rf.horizon)
+	endDate := time.Now()
+
+	// Every kind is staged first and published as one version.
+	var b batch
+	var errs []error
+	for _, kind := range rf.historyKinds() {
+		days, err := rf.storeHistory(ctx, &b, kind, rf.resumeFrom(kind, startDate), endDate)
+		if err != nil {
+			// Whatever was fetched is still published; the reconciler fills in the rest.
+			errs = append(errs, fmt.Errorf("error fetching historical %s rates (%d days cached): %w", kind, days, err))
+			continue
+		}
+		rf.logger.Log("message", "Historical rates cached successfully", "kind", kind, "days", days)
 	}
+	rf.publish(&b)
 
-	var historyRateResponse HistoricalRateAPIResponse
-	if err := json.Unmarshal(resp, &historyRateResponse); err != nil {
-		return fmt.Errorf("error unmarshalling historical rate response: %v", err)
-	}
-	rf.logger.Log("Historical rate response:", historyRateResponse)
+	return errors.Join(errs...)
 
-	Quotes := historyRateResponse.Quotes
+}
 
-	for date, rates := range Quotes {
-		
-		rf.fiatcache.Set(date, rates, 24*90*time.Hour)
+// RefreshHistory is the daily history job. It stores the previous day's final
+// rates, replacing the last live snapshot taken that day, and evicts the days
+// that have fallen out of the history horizon.
+func (rf *RateFetcher) RefreshHistory(ctx context.Context) error {
+	yesterday := time.Now().AddDate(0, 0, -1)
+
+	var b batch
+	var errs []error
+	for _, kind := range rf.historyKinds() {
+		days, err := rf.storeHistory(ctx, &b, kind, yesterday, yesterday)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error refreshing historical %s rates for %s: %w", kind, yesterday.Format(internal.DateFormat), err))
+			continue
+		}
+		rf.logger.Log("message", "Historical rates refreshed", "kind", kind, "days", days)
 	}
-	rf.logger.Log("Historical rates cached successfully")
-
-	return nil
+	rf.publish(&b)
+	evicted := rf.EvictHistory()
+	rf.logger.Log("message", "Historical rates evicted", "days", evicted)
 
+	return errors.Join(errs...)
 }
 
-func (rf *RateFetcher) CryptoRate(ctx context.Context) error {
-	endpoint := "live"
-
-	request_url := rf.apiClient.BuildCryptoUrl(endpoint)
-
-	resp, err := rf.apiClient.Get(ctx, request_url)
+// resumeFrom returns the first day of history left to fetch for kind: start, or,
+// when the store already holds every day from start on up to some day before
+// today, the last of those days, fetched again in case it was saved before its
+// rates were final. Days stored after the first missing one, such as a live
+// poll stored after a failed history fetch, do not move the resume point.
+func (rf *RateFetcher) resumeFrom(kind string, start time.Time) time.Time {
+	if rf.store == nil {
+		return start
+	}
+	dates, err := rf.store.Dates(kind)
 	if err != nil {
-		return fmt.Errorf("error getting response from api client for live rates: %v", err)
+		rf.logger.Log("Warning", "failed to list stored rates, fetching the whole history", "kind", kind, "err", err)
+		return start
+	}
+	stored := make(map[string]struct{}, len(dates))
+	for _, date := range dates {
+		stored[date] = struct{}{}
 	}
 
-	var cryptoRateAPIResponse CryptoRateAPIResponse
-	err = json.Unmarshal(resp, &cryptoRateAPIResponse)
-	if err != nil {
-		return fmt.Errorf("error unmarshalling live rate response: %v", err)
+	today := time.Now().Format(internal.DateFormat)
+	resume := start
+	for d := start; d.Format(internal.DateFormat) < today; d = d.AddDate(0, 0, 1) {
+		if _, ok := stored[d.Format(internal.DateFormat)]; !ok {
+			break
+		}
+		resume = d
 	}
-	if len(cryptoRateAPIResponse.Rates) == 0 {
-		return errors.New("no rates found in live rate response")
+	if resume != start {
+		rf.logger.Log("message", "Resuming history from the store", "kind", kind, "from", resume.Format(internal.DateFormat))
+	}
+	return resume
+}
+
+// historyKinds returns the rate kinds whose history is kept.
+func (rf *RateFetcher) historyKinds() []string {
+	if len(rf.cryptoProviders) == 0 {
+		return []string{FiatRates}
 	}
+	return []string{FiatRates, CryptoRates}
+}
 
-	exchangeRate := make(map[string]float64)
+// EvictHistory removes the rate maps older than the history horizon from both
+// caches and the store, and returns the number of days removed.
+func (rf *RateFetcher) EvictHistory() int {
+	return rf.evictBefore(time.Now().AddDate(0, 0, -rf.horizon))
+}
 
+// evictBefore removes the rate maps dated before the day of cutoff from the
+// caches, as one version, and then from the store, along with the stored
+// observations older than the start of that day. It returns the number of
+// days removed.
+func (rf *RateFetcher) evictBefore(cutoffTime time.Time) int {
+	cutoff := cutoffTime.Format(internal.DateFormat)
+	dayStart, _ := time.ParseInLocation(internal.DateFormat, cutoff, cutoffTime.Location())
+	kinds := []string{FiatRates, CryptoRates}
+
+	days := make(map[string]int, len(kinds))
+	rf.publisher.publish(func() {
+		for _, kind := range kinds {
+			rates := rf.cacheFor(kind)
+			for _, date := range rates.Keys() {
+				// Dates in DateFormat sort chronologically as strings.
+				if date < cutoff {
+					rates.Delete(date)
+					rf.forgetSource(kind, date)
+					days[kind]++
+				}
+			}
+		}
+	})
+
+	evicted := 0
+	for _, kind := range kinds {
+		// The store holds at least every day the cache does.
+		if rf.store != nil {
+			deleted, err := rf.store.DeleteBefore(kind, cutoff)
+			if err != nil {
+				rf.logger.Log("Error", "failed to evict stored rates", "kind", kind, "err", err)
+			}
+			days[kind] = max(days[kind], deleted)
+
+			if _, err := rf.store.DeleteObservationsBefore(kind, dayStart); err != nil {
+				rf.logger.Log("Error", "failed to evict stored observations", "kind", kind, "err", err)
+			}
+		}
+		evicted += days[kind]
+	}
+	return evicted
+}
 
+// historyTTL keeps a historical rate map until EvictHistory removes it.
+func (rf *RateFetcher) historyTTL() time.Duration {
+	return time.Duration(rf.horizon+1) * 24 * time.Hour
+}
 
-	for key, value := range cryptoRateAPIResponse.Rates {
+// storeHistory fetches the rates of kind from start to end, both included, and
+// stages them in b for the length of the history horizon. It returns the number
+// of days staged, which is non-zero even on error when only some chunks failed.
+func (rf *RateFetcher) storeHistory(ctx context.Context, b *batch, kind string, start, end time.Time) (int, error) {
+	providers, currencies := rf.providersFor(kind)
+	chunks, err := rf.fetchHistoryChunked(ctx, kind, providers, start, end, currencies)
 
-		if _, exists := internal.AllowedCryptoCurrencies[key]; exists {
-			exchangeRate[key+internal.BaseCurrency] = value
+	// Today's rates are still moving, so they go stale like live rates.
+	today := time.Now().Format(internal.DateFormat)
+	days := 0
+	for _, chunk := range chunks {
+		for date, dayRates := range chunk.rates {
+			ttl := rf.historyTTL()
+			if date == today {
+				ttl = rf.liveTTL
+			}
+			b.save(kind, date, dayRates, ttl, chunk.source)
 		}
+		days += len(chunk.rates)
+	}
+	return days, err
+}
+
+func (rf *RateFetcher) CryptoRate(ctx context.Context) error {
+	exchangeRate, source, err := rf.fetchLive(ctx, CryptoRates, rf.cryptoProviders, internal.CryptoCurrencies())
+	if err != nil {
+		return fmt.Errorf("error fetching crypto rates: %w", err)
 	}
 	rf.logger.Log("Exchange Rate:", exchangeRate)
 
 	today := time.Now().Format(internal.DateFormat)
 
 	// Cache the entire map of today's rates using the date as the key.
-	rf.cryptocache.Set(today, exchangeRate, 24*time.Hour)
-	rf.logger.Log("Live rates for crypto cached successfully")
+	rf.saveLive(CryptoRates, today, exchangeRate, source)
+	rf.lastUpdate.With("kind", CryptoRates).Set(float64(time.Now().Unix()))
+	rf.logger.Log("message", "Live rates for crypto cached successfully", "provider", source)
 
 	return nil
 }
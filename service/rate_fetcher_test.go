@@ -0,0 +1,666 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/log"
+	"github.com/pavankalyan767/exchange-rate-service/cache"
+	"github.com/pavankalyan767/exchange-rate-service/client"
+	"github.com/pavankalyan767/exchange-rate-service/fakeupstream"
+	"github.com/pavankalyan767/exchange-rate-service/internal"
+	"github.com/pavankalyan767/exchange-rate-service/provider"
+	"github.com/pavankalyan767/exchange-rate-service/service"
+	"github.com/pavankalyan767/exchange-rate-service/snapshot"
+	"github.com/pavankalyan767/exchange-rate-service/store"
+	"github.com/pavankalyan767/exchange-rate-service/types"
+)
+
+// stubProvider is a RateProvider returning fixed live rates.
+type stubProvider struct {
+	name  string
+	rates map[string]float64
+	err   error
+}
+
+func (p *stubProvider) Name() string { return p.name }
+
+func (p *stubProvider) LiveRates(ctx context.Context, currencies map[string]struct{}) (map[string]float64, error) {
+	return p.rates, p.err
+}
+
+func (p *stubProvider) HistoricalRates(ctx context.Context, start, end time.Time, currencies map[string]struct{}) (map[string]map[string]float64, error) {
+	return nil, provider.ErrNotSupported
+}
+
+func (p *stubProvider) SupportedCurrencies(ctx context.Context) (map[string]string, error) {
+	return nil, provider.ErrNotSupported
+}
+
+// newTestCache returns a cache that is closed when the test ends.
+func newTestCache(t *testing.T) *cache.RateCache {
+	c := cache.NewRateCache(1*time.Minute, 10*time.Second, log.NewNopLogger())
+	t.Cleanup(c.Close)
+	return c
+}
+
+func newTestFetcher(t *testing.T, fiatProviders []provider.RateProvider, opts ...service.FetcherOption) (*service.RateFetcher, *cache.RateCache) {
+	logger := log.NewLogfmtLogger(os.Stderr)
+	fiatCache := newTestCache(t)
+	cryptoCache := newTestCache(t)
+	return service.NewRateFetcher(fiatProviders, nil, fiatCache, cryptoCache, logger, opts...), fiatCache
+}
+
+func TestLiveRate_FailsOverToNextProvider(t *testing.T) {
+	fetcher, fiatCache := newTestFetcher(t, []provider.RateProvider{
+		&stubProvider{name: "primary", err: errors.New("upstream down")},
+		&stubProvider{name: "empty", rates: map[string]float64{}},
+		&stubProvider{name: "backup", rates: map[string]float64{"USDINR": 83.0}},
+	})
+
+	if err := fetcher.LiveRate(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	today := time.Now().Format(internal.DateFormat)
+	if rate, ok := fiatCache.GetRateWithDate(today, "USDINR"); !ok || rate != 83.0 {
+		t.Errorf("expected USDINR 83.00 from backup, got %.2f (found %v)", rate, ok)
+	}
+	if source, _ := fetcher.Source(service.FiatRates, today); source != "backup" {
+		t.Errorf("expected source backup, got %q", source)
+	}
+}
+
+func TestLiveRate_ConsensusOfTwoDisagreeingProvidersKeepsPrimary(t *testing.T) {
+	fetcher, fiatCache := newTestFetcher(t, []provider.RateProvider{
+		&stubProvider{name: "primary", rates: map[string]float64{"USDINR": 83.0, "USDEUR": 0.92}},
+		&stubProvider{name: "secondary", rates: map[string]float64{"USDINR": 90.0, "USDEUR": 0.921}},
+	}, service.WithConsensus(0.01, 1))
+
+	if err := fetcher.LiveRate(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// Both quotes are 4% off their median: neither can be called the outlier.
+	today := time.Now().Format(internal.DateFormat)
+	if rate, ok := fiatCache.GetRateWithDate(today, "USDINR"); !ok || rate != 83.0 {
+		t.Errorf("expected the primary USDINR 83.00, got %.2f (found %v)", rate, ok)
+	}
+	if rate, _ := fiatCache.GetRateWithDate(today, "USDEUR"); math.Abs(rate-0.9205) > 1e-9 {
+		t.Errorf("expected the median USDEUR 0.9205 of agreeing quotes, got %v", rate)
+	}
+	if source, _ := fetcher.Source(service.FiatRates, today); source != "consensus:primary,secondary" {
+		t.Errorf("expected both providers to be credited, got %q", source)
+	}
+}
+
+// recordingGauge keeps the last value set for each set of label values.
+type recordingGauge struct {
+	mutex  *sync.Mutex
+	values map[string]float64
+	labels []string
+}
+
+func newRecordingGauge() *recordingGauge {
+	return &recordingGauge{mutex: &sync.Mutex{}, values: map[string]float64{}}
+}
+
+func (g *recordingGauge) With(labelValues ...string) metrics.Gauge {
+	return &recordingGauge{mutex: g.mutex, values: g.values, labels: append(append([]string{}, g.labels...), labelValues...)}
+}
+
+func (g *recordingGauge) Set(value float64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.values[strings.Join(g.labels, ",")] = value
+}
+
+func (g *recordingGauge) Add(delta float64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.values[strings.Join(g.labels, ",")] += delta
+}
+
+func TestLiveRate_SetsLastUpdateByKind(t *testing.T) {
+	lastUpdate := newRecordingGauge()
+	fetcher, _ := newTestFetcher(t, []provider.RateProvider{
+		&stubProvider{name: "a", rates: map[string]float64{"USDINR": 83.0}},
+		&stubProvider{name: "b", rates: map[string]float64{"USDINR": 83.1}},
+	}, service.WithConsensus(0.01, 2), service.WithUpdateMetric(lastUpdate))
+
+	before := time.Now().Unix()
+	if err := fetcher.LiveRate(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// The consensus source names both providers, but the series is per kind only.
+	if len(lastUpdate.values) != 1 {
+		t.Errorf("expected a single series, got %v", lastUpdate.values)
+	}
+	if updated, ok := lastUpdate.values["kind,fiat"]; !ok || updated < float64(before) {
+		t.Errorf("expected the fiat update time to be set, got %v", lastUpdate.values)
+	}
+}
+
+func TestLiveRate_ConsensusDiscardsOutliers(t *testing.T) {
+	fetcher, fiatCache := newTestFetcher(t, []provider.RateProvider{
+		&stubProvider{name: "a", rates: map[string]float64{"USDINR": 83.0}},
+		&stubProvider{name: "b", rates: map[string]float64{"USDINR": 83.2}},
+		&stubProvider{name: "c", rates: map[string]float64{"USDINR": 90.0}},
+	}, service.WithConsensus(0.01, 2))
+
+	if err := fetcher.LiveRate(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	today := time.Now().Format(internal.DateFormat)
+	rate, ok := fiatCache.GetRateWithDate(today, "USDINR")
+	if !ok {
+		t.Fatalf("expected USDINR to reach consensus")
+	}
+	if expected := 83.1; rate != expected {
+		t.Errorf("expected median %.2f of agreeing quotes, got %.2f", expected, rate)
+	}
+	// The outlier is not credited with the day's rates.
+	if source, _ := fetcher.Source(service.FiatRates, today); source != "consensus:a,b" {
+		t.Errorf("expected source consensus:a,b, got %q", source)
+	}
+}
+
+func TestRateFetcher_AgainstFakeUpstream(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	apiClient := client.NewAPIClient("fake", logger, client.WithRetryPolicy(client.RetryPolicy{MaxAttempts: 1}))
+	fiatCache := newTestCache(t)
+	cryptoCache := newTestCache(t)
+	fetcher := service.NewRateFetcher(
+		[]provider.RateProvider{provider.NewCurrencyLayer(upstream.FiatURL(), "key", apiClient)},
+		[]provider.RateProvider{provider.NewCoinLayer(upstream.CryptoURL(), "key", apiClient)},
+		fiatCache, cryptoCache, logger,
+	)
+
+	ctx := context.Background()
+	if err := fetcher.LiveRate(ctx); err != nil {
+		t.Fatalf("expected no error fetching live rates, got %v", err)
+	}
+	if err := fetcher.CryptoRate(ctx); err != nil {
+		t.Fatalf("expected no error fetching crypto rates, got %v", err)
+	}
+	if err := fetcher.HistoricalRate(ctx); err != nil {
+		t.Fatalf("expected no error fetching historical rates, got %v", err)
+	}
+
+	svc := service.NewExchangeRateServiceImpl(fiatCache, cryptoCache)
+	rate, _, err := svc.FetchRate(ctx, &types.FetchRateRequest{BaseCurrency: "BTC", TargetCurrency: "INR"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if expected := 30000.0 * 83.0; rate != expected {
+		t.Errorf("expected %.2f, got %.2f", expected, rate)
+	}
+
+	from := time.Now().AddDate(0, 0, -10).Format(internal.DateFormat)
+	history, _, err := svc.History(ctx, &types.HistoryRequest{BaseCurrency: "EUR", TargetCurrency: "GBP", From: from, To: time.Now().Format(internal.DateFormat)})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(history) != 11 {
+		t.Errorf("expected 11 days of history, got %d", len(history))
+	}
+}
+
+func TestLiveRate_FailsOverOnMalformedResponse(t *testing.T) {
+	primary, backup := fakeupstream.New(), fakeupstream.New()
+	primarySrv, backupSrv := primary.Start(), backup.Start()
+	defer primarySrv.Close()
+	defer backupSrv.Close()
+	primary.Enqueue(fakeupstream.FiatLive, fakeupstream.MalformedJSON)
+	backup.SetFiatQuotes(map[string]float64{"USDINR": 84.0})
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	fetcher, fiatCache := newTestFetcher(t, []provider.RateProvider{
+		provider.NewCurrencyLayer(primary.FiatURL(), "key", client.NewAPIClient("primary", logger)),
+		provider.NewCurrencyLayer(backup.FiatURL(), "key", client.NewAPIClient("backup", logger)),
+	})
+
+	if err := fetcher.LiveRate(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	today := time.Now().Format(internal.DateFormat)
+	if rate, _ := fiatCache.GetRateWithDate(today, "USDINR"); rate != 84.0 {
+		t.Errorf("expected USDINR 84.00 from backup, got %.2f", rate)
+	}
+}
+
+func TestRefreshHistory_StoresYesterdayAndEvictsOldDays(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+
+	yesterday := time.Now().AddDate(0, 0, -1).Format(internal.DateFormat)
+	upstream.SetHistory(yesterday, map[string]float64{"USDINR": 83.5})
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	fetcher, fiatCache := newTestFetcher(t, []provider.RateProvider{
+		provider.NewCurrencyLayer(upstream.FiatURL(), "key", client.NewAPIClient("fake", logger)),
+	}, service.WithHistoryHorizon(7))
+
+	expired := time.Now().AddDate(0, 0, -8).Format(internal.DateFormat)
+	kept := time.Now().AddDate(0, 0, -7).Format(internal.DateFormat)
+	fiatCache.Set(expired, types.RateTable{"USDINR": 80.0}, time.Hour)
+	fiatCache.Set(kept, types.RateTable{"USDINR": 81.0}, time.Hour)
+
+	if err := fetcher.RefreshHistory(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if rate, _ := fiatCache.GetRateWithDate(yesterday, "USDINR"); rate != 83.5 {
+		t.Errorf("expected yesterday's USDINR 83.50, got %.2f", rate)
+	}
+	if _, ok := fiatCache.Get(expired); ok {
+		t.Errorf("expected %s to be evicted", expired)
+	}
+	if _, ok := fiatCache.Get(kept); !ok {
+		t.Errorf("expected %s to be kept", kept)
+	}
+}
+
+func TestReconcile_BackfillsMissingDaysAndPairs(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	fetcher, fiatCache := newTestFetcher(t, []provider.RateProvider{
+		provider.NewCurrencyLayer(upstream.FiatURL(), "key", client.NewAPIClient("fake", logger)),
+	}, service.WithHistoryHorizon(4))
+
+	day := func(offset int) string { return time.Now().AddDate(0, 0, offset).Format(internal.DateFormat) }
+	complete := types.RateTable{"USDUSD": 1, "USDINR": 80, "USDEUR": 0.9, "USDJPY": 150, "USDGBP": 0.8}
+	fiatCache.Set(day(-4), complete, time.Hour)
+	fiatCache.Set(day(-3), complete, time.Hour)
+	snap := snapshot.New()
+	snap.Entries = append(snap.Entries, snapshot.Entry{
+		Kind: service.FiatRates, Date: day(-2), Rates: types.RateTable{"USDINR": 80, "USDEUR": 0, "USDJPY": 150, "USDGBP": 0.8}, Source: "archive",
+	})
+	if _, err := fetcher.Import(snap); err != nil {
+		t.Fatalf("expected no error importing, got %v", err)
+	}
+
+	if err := fetcher.Reconcile(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// Days -2 and -1 form a single gap.
+	if requests := upstream.Requests(fakeupstream.FiatTimeframe); requests != 1 {
+		t.Errorf("expected 1 timeframe request, got %d", requests)
+	}
+	if rate, _ := fiatCache.GetRateWithDate(day(-2), "USDEUR"); rate != 0.91 {
+		t.Errorf("expected backfilled USDEUR 0.91 on %s, got %.2f", day(-2), rate)
+	}
+	if rate, _ := fiatCache.GetRateWithDate(day(-2), "USDINR"); rate != 80 {
+		t.Errorf("expected USDINR 80.00 to be kept on %s, got %.2f", day(-2), rate)
+	}
+	if rate, _ := fiatCache.GetRateWithDate(day(-1), "USDGBP"); rate != 0.79 {
+		t.Errorf("expected backfilled USDGBP 0.79 on %s, got %.2f", day(-1), rate)
+	}
+
+	// A completed day keeps its original source next to the gap-fill provider.
+	if source, _ := fetcher.Source(service.FiatRates, day(-2)); source != "archive+fake" {
+		t.Errorf("expected source archive+fake on %s, got %q", day(-2), source)
+	}
+	if source, _ := fetcher.Source(service.FiatRates, day(-1)); source != "fake" {
+		t.Errorf("expected source fake on %s, got %q", day(-1), source)
+	}
+}
+
+func TestReconcile_BackfillsCryptoHistory(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	coverage := newRecordingGauge()
+	fiatCache := newTestCache(t)
+	cryptoCache := newTestCache(t)
+	fetcher := service.NewRateFetcher(
+		[]provider.RateProvider{provider.NewCurrencyLayer(upstream.FiatURL(), "key", client.NewAPIClient("fiat", logger))},
+		[]provider.RateProvider{provider.NewCoinLayer(upstream.CryptoURL(), "key", client.NewAPIClient("crypto", logger))},
+		fiatCache, cryptoCache, logger, service.WithHistoryHorizon(2), service.WithCoverageMetric(coverage),
+	)
+
+	day := func(offset int) string { return time.Now().AddDate(0, 0, offset).Format(internal.DateFormat) }
+	cryptoCache.Set(day(-2), types.RateTable{"BTCUSD": 28000, "ETHUSD": 1700}, time.Hour)
+
+	if err := fetcher.Reconcile(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if requests := upstream.Requests(fakeupstream.CryptoTimeframe); requests != 1 {
+		t.Errorf("expected 1 crypto timeframe request, got %d", requests)
+	}
+	if rate, _ := cryptoCache.GetRateWithDate(day(-2), "USDTUSD"); rate != 1 {
+		t.Errorf("expected backfilled USDTUSD 1.00 on %s, got %.2f", day(-2), rate)
+	}
+	if rate, _ := cryptoCache.GetRateWithDate(day(-2), "BTCUSD"); rate != 28000 {
+		t.Errorf("expected BTCUSD 28000 to be kept on %s, got %.2f", day(-2), rate)
+	}
+	if rate, _ := cryptoCache.GetRateWithDate(day(-1), "BTCUSD"); rate != 30000 {
+		t.Errorf("expected backfilled BTCUSD 30000 on %s, got %.2f", day(-1), rate)
+	}
+	for _, kind := range []string{service.FiatRates, service.CryptoRates} {
+		if percent, ok := coverage.values["kind,"+kind]; !ok || percent != 100 {
+			t.Errorf("expected full %s coverage, got %v", kind, coverage.values)
+		}
+	}
+}
+
+func TestHistoricalRate_FetchesLongRangesInChunks(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	fetcher, fiatCache := newTestFetcher(t, []provider.RateProvider{
+		provider.NewCurrencyLayer(upstream.FiatURL(), "key", client.NewAPIClient("fake", logger)),
+	}, service.WithHistoryHorizon(12), service.WithHistoryChunks(5, 2))
+
+	if err := fetcher.HistoricalRate(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// 13 days, from the horizon through today, in chunks of 5, 5 and 3 days.
+	if requests := upstream.Requests(fakeupstream.FiatTimeframe); requests != 3 {
+		t.Errorf("expected 3 timeframe requests, got %d", requests)
+	}
+	for offset := -12; offset <= 0; offset++ {
+		date := time.Now().AddDate(0, 0, offset).Format(internal.DateFormat)
+		if _, ok := fiatCache.GetRateWithDate(date, "USDINR"); !ok {
+			t.Errorf("expected rates for %s", date)
+		}
+	}
+}
+
+func TestHistory_CryptoAndCrossPairs(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+
+	yesterday := time.Now().AddDate(0, 0, -1).Format(internal.DateFormat)
+	upstream.SetCryptoHistory(yesterday, map[string]float64{"BTC": 28000, "ETH": 1700, "USDT": 1})
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	fiatCache := newTestCache(t)
+	cryptoCache := newTestCache(t)
+	fetcher := service.NewRateFetcher(
+		[]provider.RateProvider{provider.NewCurrencyLayer(upstream.FiatURL(), "key", client.NewAPIClient("fiat", logger))},
+		[]provider.RateProvider{provider.NewCoinLayer(upstream.CryptoURL(), "key", client.NewAPIClient("crypto", logger))},
+		fiatCache, cryptoCache, logger, service.WithHistoryHorizon(3),
+	)
+	if err := fetcher.HistoricalRate(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	svc := service.NewExchangeRateServiceImpl(fiatCache, cryptoCache)
+	from := time.Now().AddDate(0, 0, -3).Format(internal.DateFormat)
+	to := time.Now().Format(internal.DateFormat)
+
+	history, _, err := svc.History(context.Background(), &types.HistoryRequest{BaseCurrency: "BTC", TargetCurrency: "ETH", From: from, To: to})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if expected := 28000.0 / 1700.0; history[yesterday] != expected {
+		t.Errorf("expected BTC/ETH %.4f on %s, got %.4f", expected, yesterday, history[yesterday])
+	}
+
+	history, _, err = svc.History(context.Background(), &types.HistoryRequest{BaseCurrency: "BTC", TargetCurrency: "INR", From: from, To: to})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(history) != 4 {
+		t.Errorf("expected 4 days of history, got %d", len(history))
+	}
+	if expected := 28000.0 * 83.0; history[yesterday] != expected {
+		t.Errorf("expected BTC/INR %.2f on %s, got %.2f", expected, yesterday, history[yesterday])
+	}
+}
+
+func TestStore_SurvivesRestart(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+
+	st, err := store.Open(filepath.Join(t.TempDir(), "rates.db"))
+	if err != nil {
+		t.Fatalf("expected no error opening store, got %v", err)
+	}
+	defer st.Close()
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	providers := []provider.RateProvider{
+		provider.NewCurrencyLayer(upstream.FiatURL(), "key", client.NewAPIClient("fake", logger)),
+	}
+	fetcher, _ := newTestFetcher(t, providers, service.WithHistoryHorizon(30), service.WithStore(st))
+	if err := fetcher.HistoricalRate(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// A restarted service starts with empty caches and reads the history from the store.
+	restarted, fiatCache := newTestFetcher(t, providers, service.WithHistoryHorizon(30), service.WithHistoryChunks(1, 1), service.WithStore(st))
+	svc := service.NewExchangeRateServiceImpl(fiatCache, newTestCache(t), service.WithStoreFallback(st))
+	date := time.Now().AddDate(0, 0, -20).Format(internal.DateFormat)
+	rate, _, err := svc.FetchRate(context.Background(), &types.FetchRateRequest{BaseCurrency: "USD", TargetCurrency: "INR", Date: date})
+	if err != nil || rate != 83.0 {
+		t.Errorf("expected USDINR 83.00 on %s from the store, got %.2f (err %v)", date, rate, err)
+	}
+
+	// Only yesterday, the latest stored day before today, and today are fetched again.
+	before := upstream.Requests(fakeupstream.FiatTimeframe)
+	if err := restarted.HistoricalRate(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if requests := upstream.Requests(fakeupstream.FiatTimeframe) - before; requests != 2 {
+		t.Errorf("expected 2 one-day timeframe requests, got %d", requests)
+	}
+}
+
+func TestHistoricalRate_ResumesOnlyOverUnbrokenStoredDays(t *testing.T) {
+	upstream := fakeupstream.New()
+	srv := upstream.Start()
+	defer srv.Close()
+
+	st, err := store.Open(filepath.Join(t.TempDir(), "rates.db"))
+	if err != nil {
+		t.Fatalf("expected no error opening store, got %v", err)
+	}
+	defer st.Close()
+
+	// The history fetch failed after two days, and then a live poll was stored.
+	day := func(offset int) string { return time.Now().AddDate(0, 0, offset).Format(internal.DateFormat) }
+	var entries []store.Entry
+	for _, offset := range []int{-5, -4, -1} {
+		record := store.Record{Rates: map[string]float64{"USDINR": 83.0}, Source: "fake", FetchedAt: time.Now()}
+		entries = append(entries, store.Entry{Kind: service.FiatRates, Date: day(offset), Record: record})
+	}
+	if err := st.PutAll(entries); err != nil {
+		t.Fatalf("expected no error storing rates, got %v", err)
+	}
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	providers := []provider.RateProvider{
+		provider.NewCurrencyLayer(upstream.FiatURL(), "key", client.NewAPIClient("fake", logger)),
+	}
+	fetcher, fiatCache := newTestFetcher(t, providers, service.WithHistoryHorizon(5), service.WithHistoryChunks(1, 1), service.WithStore(st))
+	if err := fetcher.HistoricalRate(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// The last day before the hole, the hole and every later day are fetched.
+	if requests := upstream.Requests(fakeupstream.FiatTimeframe); requests != 5 {
+		t.Errorf("expected 5 one-day timeframe requests, got %d", requests)
+	}
+	for _, offset := range []int{-3, -2} {
+		if _, ok := fiatCache.GetRateWithDate(day(offset), "USDINR"); !ok {
+			t.Errorf("expected the missing %s to be fetched", day(offset))
+		}
+	}
+}
+
+func TestPublisher_ReadersNeverMixVersions(t *testing.T) {
+	publisher := service.NewPublisher()
+	fiatCache, cryptoCache := newTestCache(t), newTestCache(t)
+	fetcher := service.NewRateFetcher(nil, nil, fiatCache, cryptoCache, log.NewNopLogger(), service.WithPublisher(publisher))
+	svc := service.NewExchangeRateServiceImpl(fiatCache, cryptoCache, service.WithPublishedReads(publisher))
+
+	// Every published batch prices BTC at exactly 1 INR, through rates that
+	// differ from batch to batch, so a read mixing two batches is off.
+	today := time.Now().Format(internal.DateFormat)
+	publish := func(k float64) {
+		snap := snapshot.New()
+		snap.Entries = append(snap.Entries,
+			snapshot.Entry{Kind: service.FiatRates, Date: today, Rates: types.RateTable{"USDINR": k}},
+			snapshot.Entry{Kind: service.CryptoRates, Date: today, Rates: types.RateTable{"BTCUSD": 1 / k}},
+		)
+		if _, err := fetcher.Import(snap); err != nil {
+			t.Errorf("expected no error importing, got %v", err)
+		}
+	}
+	publish(1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for k := 2; k <= 500; k++ {
+			publish(float64(k))
+		}
+	}()
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var last uint64
+			for range 500 {
+				rate, freshness, err := svc.FetchRate(context.Background(), &types.FetchRateRequest{BaseCurrency: "BTC", TargetCurrency: "INR"})
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+					return
+				}
+				if math.Abs(rate-1) > 1e-9 {
+					t.Errorf("expected BTC/INR 1 from a single version, got %v at version %d", rate, freshness.Version)
+					return
+				}
+				if freshness.Version < last {
+					t.Errorf("expected versions not to go back, got %d after %d", freshness.Version, last)
+				}
+				last = freshness.Version
+			}
+		}()
+	}
+	wg.Wait()
+
+	if publisher.Version() != 500 {
+		t.Errorf("expected 500 published versions, got %d", publisher.Version())
+	}
+}
+
+func TestFetchRate_AtInstantReturnsNearestEarlierPoll(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "rates.db"))
+	if err != nil {
+		t.Fatalf("expected no error opening store, got %v", err)
+	}
+	defer st.Close()
+
+	upstream := &stubProvider{name: "stub", rates: map[string]float64{"USDINR": 83.0}}
+	fetcher, fiatCache := newTestFetcher(t, []provider.RateProvider{upstream}, service.WithStore(st))
+	svc := service.NewExchangeRateServiceImpl(fiatCache, newTestCache(t), service.WithStoreFallback(st))
+
+	if err := fetcher.LiveRate(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	between := time.Now()
+	upstream.rates = map[string]float64{"USDINR": 84.0}
+	if err := fetcher.LiveRate(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// The second poll replaced today's rates, but the first is still observed.
+	request := &types.FetchRateRequest{BaseCurrency: "USD", TargetCurrency: "INR", At: between.Format(time.RFC3339Nano)}
+	rate, freshness, err := svc.FetchRate(context.Background(), request)
+	if err != nil || rate != 83.0 {
+		t.Errorf("expected USDINR 83.00 at %s, got %.2f (err %v)", request.At, rate, err)
+	}
+	if freshness.ObservedAt.IsZero() || freshness.ObservedAt.After(between) {
+		t.Errorf("expected an observation at or before %s, got %s", between, freshness.ObservedAt)
+	}
+
+	request.At = time.Now().Format(time.RFC3339Nano)
+	if rate, _, err := svc.FetchRate(context.Background(), request); err != nil || rate != 84.0 {
+		t.Errorf("expected USDINR 84.00 now, got %.2f (err %v)", rate, err)
+	}
+
+	request.At = between.Add(-time.Hour).Format(time.RFC3339)
+	if _, _, err := svc.FetchRate(context.Background(), request); err == nil {
+		t.Error("expected an error before the first poll")
+	}
+}
+
+func TestFetchRate_AtInstantOfPastDayUsesDailyRate(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "rates.db"))
+	if err != nil {
+		t.Fatalf("expected no error opening store, got %v", err)
+	}
+	defer st.Close()
+
+	fetcher, fiatCache := newTestFetcher(t, nil, service.WithStore(st))
+	svc := service.NewExchangeRateServiceImpl(fiatCache, newTestCache(t), service.WithStoreFallback(st))
+
+	// History is fetched after its day is over, so it has no observation on it.
+	yesterday := time.Now().AddDate(0, 0, -1)
+	snap := snapshot.New()
+	snap.Entries = append(snap.Entries, snapshot.Entry{
+		Kind: service.FiatRates, Date: yesterday.Format(internal.DateFormat), Rates: types.RateTable{"USDINR": 83.0}, FetchedAt: time.Now(),
+	})
+	if _, err := fetcher.Import(snap); err != nil {
+		t.Fatalf("expected no error importing, got %v", err)
+	}
+
+	noon := time.Date(yesterday.Year(), yesterday.Month(), yesterday.Day(), 12, 0, 0, 0, time.Local)
+	request := &types.FetchRateRequest{BaseCurrency: "USD", TargetCurrency: "INR", At: noon.Format(time.RFC3339)}
+	rate, freshness, err := svc.FetchRate(context.Background(), request)
+	if err != nil || rate != 83.0 {
+		t.Fatalf("expected the daily USDINR 83.00 at %s, got %.2f (err %v)", request.At, rate, err)
+	}
+	if day := freshness.ObservedAt.Format(internal.DateFormat); day != snap.Entries[0].Date || freshness.ObservedAt.Hour() != 23 {
+		t.Errorf("expected the rate to be observed at the end of %s, got %s", snap.Entries[0].Date, freshness.ObservedAt)
+	}
+}
+
+func TestImport_RejectsUnknownKind(t *testing.T) {
+	fetcher, fiatCache := newTestFetcher(t, nil)
+
+	today := time.Now().Format(internal.DateFormat)
+	snap := snapshot.New()
+	snap.Entries = append(snap.Entries,
+		snapshot.Entry{Kind: service.FiatRates, Date: today, Rates: types.RateTable{"USDINR": 83.0}},
+		snapshot.Entry{Kind: "metals", Date: today, Rates: types.RateTable{"XAUUSD": 2400.0}},
+	)
+	if _, err := fetcher.Import(snap); err == nil || !strings.Contains(err.Error(), "unknown kind") {
+		t.Errorf("expected an unknown kind error, got %v", err)
+	}
+	// Nothing is imported when any entry is rejected.
+	if _, ok := fiatCache.Get(today); ok {
+		t.Error("expected no rates to be imported")
+	}
+}
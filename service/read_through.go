@@ -0,0 +1,31 @@
+package service
+
+import (
+	"time"
+
+	"github.com/pavankalyan767/exchange-rate-service/cache"
+	"github.com/pavankalyan767/exchange-rate-service/store"
+	"github.com/pavankalyan767/exchange-rate-service/types"
+)
+
+// storeHotTTL is how long a rate map loaded from the store stays in the cache.
+const storeHotTTL = 24 * time.Hour
+
+// readThrough returns the rate map of kind for date, which may be stale. The
+// cache is the hot layer: on a miss the map is loaded from st, when there is
+// one, and cached again. A store that cannot be read is treated as a miss.
+func readThrough(c *cache.RateCache, st *store.Store, kind, date string) (cache.Item[types.RateTable], bool) {
+	if item, ok := c.Lookup(date); ok {
+		return item, true
+	}
+	if st == nil {
+		return cache.Item[types.RateTable]{}, false
+	}
+
+	record, ok, err := st.Get(kind, date)
+	if err != nil || !ok {
+		return cache.Item[types.RateTable]{}, false
+	}
+	c.SetAt(date, record.Rates, storeHotTTL, record.FetchedAt)
+	return cache.Item[types.RateTable]{Value: record.Rates, Age: time.Since(record.FetchedAt)}, true
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/pavankalyan767/exchange-rate-service/internal"
+	"github.com/pavankalyan767/exchange-rate-service/types"
+)
+
+// gap is a run of consecutive days whose rate maps of one kind are missing, or
+// lack some of the allowed currencies.
+type gap struct {
+	start, end time.Time
+	currencies map[string]struct{}
+}
+
+// Reconcile scans the history window of every kind whose history is kept, from
+// the horizon up to yesterday, for days with no rate map or with missing pairs,
+// and fetches just those days and currencies from the upstream. Each run of
+// consecutive incomplete days is fetched as one range, split into chunks only
+// where it exceeds the provider limit. The share of the window that is filled
+// afterwards is reported on the coverage gauge, per kind.
+func (rf *RateFetcher) Reconcile(ctx context.Context) error {
+	var errs []error
+	for _, kind := range rf.historyKinds() {
+		gaps, _, _ := rf.findGaps(kind)
+
+		filled := 0
+		for _, g := range gaps {
+			if ctx.Err() != nil {
+				errs = append(errs, ctx.Err())
+				break
+			}
+			days, err := rf.fillGap(ctx, kind, g)
+			filled += days
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", kind, err))
+			}
+		}
+
+		_, missing, total := rf.findGaps(kind)
+		coverage := 100.0
+		if total > 0 {
+			coverage = 100 * float64(total-missing) / float64(total)
+		}
+		rf.coverage.With("kind", kind).Set(coverage)
+		rf.logger.Log("message", "History reconciled", "kind", kind, "gaps", len(gaps), "days_filled", filled, "coverage_percent", coverage)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("error backfilling historical rates: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// findGaps returns the incomplete runs of days of kind in the history window,
+// along with the number of missing and expected (day, pair) slots.
+func (rf *RateFetcher) findGaps(kind string) (gaps []gap, missing, total int) {
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+
+	_, currencies := rf.providersFor(kind)
+	expected := len(currencies)
+	if _, ok := currencies[internal.BaseCurrency]; ok {
+		expected--
+	}
+	open := -1
+	for d := now.AddDate(0, 0, -rf.horizon); !d.After(yesterday); d = d.AddDate(0, 0, 1) {
+		absent := rf.missingCurrencies(kind, d.Format(internal.DateFormat), currencies)
+		total += expected
+		missing += len(absent)
+
+		if len(absent) == 0 {
+			open = -1
+			continue
+		}
+		if open < 0 {
+			gaps = append(gaps, gap{start: d, currencies: map[string]struct{}{}})
+			open = len(gaps) - 1
+		}
+		gaps[open].end = d
+		maps.Copy(gaps[open].currencies, absent)
+	}
+	return gaps, missing, total
+}
+
+// missingCurrencies returns the currencies, out of the given ones, with no
+// usable rate against the base currency in the rate map of kind on date.
+func (rf *RateFetcher) missingCurrencies(kind, date string, currencies map[string]struct{}) map[string]struct{} {
+	item, _ := readThrough(rf.cacheFor(kind), rf.store, kind, date)
+	rates := item.Value
+
+	missing := map[string]struct{}{}
+	for currency := range currencies {
+		if currency == internal.BaseCurrency {
+			continue
+		}
+		// Live rate maps hold 0 for the pairs the provider did not quote.
+		if rates[pairKey(kind, currency)] <= 0 {
+			missing[currency] = struct{}{}
+		}
+	}
+	return missing
+}
+
+// pairKey returns the key of currency's rate in a rate map of kind: fiat
+// currencies are quoted in units per base currency, crypto currencies in base
+// currency per coin.
+func pairKey(kind, currency string) string {
+	if kind == CryptoRates {
+		return currency + internal.BaseCurrency
+	}
+	return internal.BaseCurrency + currency
+}
+
+// fillGap fetches the missing currencies of g from the providers of kind and
+// adds them to the cached rate maps. Rates already present are kept, since a
+// gap may span days that miss different currencies, and so is the provenance of
+// a completed day; see mergedSource. It returns the number of days updated,
+// which is non-zero even on error when only some chunks failed.
+func (rf *RateFetcher) fillGap(ctx context.Context, kind string, g gap) (int, error) {
+	providers, _ := rf.providersFor(kind)
+	chunks, err := rf.fetchHistoryChunked(ctx, kind, providers, g.start, g.end, g.currencies)
+
+	var b batch
+	days := 0
+	for _, chunk := range chunks {
+		for date, rates := range chunk.rates {
+			merged := types.RateTable{}
+			existing, ok := readThrough(rf.cacheFor(kind), rf.store, kind, date)
+			if ok {
+				maps.Copy(merged, existing.Value)
+			}
+			for pair, rate := range rates {
+				if merged[pair] <= 0 {
+					merged[pair] = rate
+				}
+			}
+			source := chunk.source
+			if ok {
+				original, _ := rf.Source(kind, date)
+				source = mergedSource(original, chunk.source)
+			}
+			b.save(kind, date, merged, rf.historyTTL(), source)
+		}
+		days += len(chunk.rates)
+	}
+	rf.publish(&b)
+	return days, err
+}
+
+// mergedSource names the providers of a rate map that a gap fill from fill
+// completed: the original source, followed by fill when it differs, or fill
+// alone when the original source is unknown.
+func mergedSource(original, fill string) string {
+	if original == "" || original == fill {
+		return fill
+	}
+	return original + "+" + fill
+}
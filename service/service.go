@@ -3,36 +3,167 @@ package service
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/pavankalyan767/exchange-rate-service/cache"
 	"github.com/pavankalyan767/exchange-rate-service/internal"
+	"github.com/pavankalyan767/exchange-rate-service/store"
 	"github.com/pavankalyan767/exchange-rate-service/types"
 )
 
 type ExchangeRateService interface {
-	FetchRate(ctx context.Context, request *types.FetchRateRequest) (float64, error)
-	Convert(ctx context.Context, request *types.ConvertRequest) (float64, error)
+	FetchRate(ctx context.Context, request *types.FetchRateRequest) (float64, types.Freshness, error)
+	Convert(ctx context.Context, request *types.ConvertRequest) (float64, types.Freshness, error)
 
-	History(ctx context.Context, request *types.HistoryRequest) (map[string]float64, error)
+	History(ctx context.Context, request *types.HistoryRequest) (map[string]float64, types.Freshness, error)
 }
 
 type ExchangeRateServiceImpl struct {
-	fiatcache   *cache.Cache
-	cryptocache *cache.Cache
+	fiatcache   *cache.RateCache
+	cryptocache *cache.RateCache
+	store       *store.Store
+	publisher   *Publisher
+
+	refresh         func(kind string)
+	refreshInterval time.Duration
+	refreshMutex    sync.Mutex
+	lastRefresh     map[string]time.Time
+}
+
+// ServiceOption configures optional ExchangeRateServiceImpl behaviour.
+type ServiceOption func(*ExchangeRateServiceImpl)
+
+// WithStoreFallback reads the rate maps missing from the caches from st, which
+// makes the caches a hot layer in front of the persistent store.
+func WithStoreFallback(st *store.Store) ServiceOption {
+	return func(s *ExchangeRateServiceImpl) {
+		s.store = st
+	}
 }
 
-func NewExchangeRateServiceImpl(fiatcache, cryptocache *cache.Cache) *ExchangeRateServiceImpl {
-	return &ExchangeRateServiceImpl{
+// WithPublishedReads makes every request read all its rates from a single
+// version published through p, the Publisher shared with the RateFetcher (see
+// WithPublisher), and report that version.
+func WithPublishedReads(p *Publisher) ServiceOption {
+	return func(s *ExchangeRateServiceImpl) {
+		s.publisher = p
+	}
+}
+
+// WithStaleRefresh calls refresh with the kind of rates whenever a stale rate
+// map of that kind is served, at most once per interval, so that the next
+// read finds fresh rates.
+func WithStaleRefresh(refresh func(kind string), interval time.Duration) ServiceOption {
+	return func(s *ExchangeRateServiceImpl) {
+		s.refresh = refresh
+		s.refreshInterval = interval
+	}
+}
+
+func NewExchangeRateServiceImpl(fiatcache, cryptocache *cache.RateCache, opts ...ServiceOption) *ExchangeRateServiceImpl {
+	s := &ExchangeRateServiceImpl{
 		fiatcache:   fiatcache,
 		cryptocache: cryptocache,
+		publisher:   NewPublisher(),
+		lastRefresh: map[string]time.Time{},
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
+
+}
+
+// rateLookup resolves the rates of one request and records how fresh they are.
+type rateLookup struct {
+	s         *ExchangeRateServiceImpl
+	freshness types.Freshness
+
+	// at, when set, looks rates up at that instant rather than by date; see rateAt.
+	at           time.Time
+	observations map[string]observation
+}
+
+// resolve runs fn with a new lookup until no batch was published while it ran,
+// so that every rate it read belongs to the same version, and returns the
+// freshness of those rates.
+func (s *ExchangeRateServiceImpl) resolve(fn func(l *rateLookup) error) (types.Freshness, error) {
+	return s.resolveAt(time.Time{}, fn)
+}
 
+// resolveAt is like resolve for a lookup at the instant at, unless it is zero.
+func (s *ExchangeRateServiceImpl) resolveAt(at time.Time, fn func(l *rateLookup) error) (types.Freshness, error) {
+	var l *rateLookup
+	var err error
+	version := s.publisher.read(func() {
+		l = &rateLookup{s: s, at: at}
+		err = fn(l)
+	})
+	l.freshness.Version = version
+	return l.freshness, err
 }
-func (s *ExchangeRateServiceImpl) getRateForCurrencies(base, target, date string) (float64, error) {
-	// If the date is not provided, use the current date.
+
+// rate returns the rate of pair on date from the rate maps of kind. A stale
+// rate map of today is still used, and a refresh of its kind is requested. The
+// rate map of a past day is settled: it is never reported as stale, since a
+// live poll could not refresh it. A lookup at an instant ignores date.
+func (l *rateLookup) rate(kind, date, pair string) (float64, bool) {
+	if !l.at.IsZero() {
+		return l.rateAt(kind, pair)
+	}
+	item, ok := readThrough(l.s.cacheFor(kind), l.s.store, kind, date)
+	if !ok {
+		return 0, false
+	}
+	rate, ok := item.Value.Rate(pair)
+	if ok {
+		// Dates in DateFormat sort chronologically as strings.
+		stale := item.Stale && date >= time.Now().Format(internal.DateFormat)
+		l.freshness.Add(item.Age, stale)
+		if stale {
+			l.s.requestRefresh(kind)
+		}
+	}
+	return rate, ok
+}
+
+// cacheFor returns the cache holding the rate maps of kind.
+func (s *ExchangeRateServiceImpl) cacheFor(kind string) *cache.RateCache {
+	if kind == CryptoRates {
+		return s.cryptocache
+	}
+	return s.fiatcache
+}
+
+// requestRefresh asks for the rates of kind to be refreshed, unless it already
+// did within the refresh interval.
+func (s *ExchangeRateServiceImpl) requestRefresh(kind string) {
+	if s.refresh == nil {
+		return
+	}
+
+	s.refreshMutex.Lock()
+	now := time.Now()
+	due := now.Sub(s.lastRefresh[kind]) >= s.refreshInterval
+	if due {
+		s.lastRefresh[kind] = now
+	}
+	s.refreshMutex.Unlock()
+
+	if due {
+		s.refresh(kind)
+	}
+}
+
+func (l *rateLookup) getRateForCurrencies(base, target, date string) (float64, error) {
+	// If the date is not provided, use the current date, or name the instant
+	// the rates are looked up at.
 	if date == "" {
 		date = time.Now().Format(internal.DateFormat)
+		if !l.at.IsZero() {
+			date = l.at.Format(time.RFC3339)
+		}
 	}
 
 	baseIsFiat := internal.IsFiatCurrency(base)
@@ -44,20 +175,20 @@ func (s *ExchangeRateServiceImpl) getRateForCurrencies(base, target, date string
 		// Direct lookup for USD to any fiat.
 		if base == internal.BaseCurrency {
 			key := internal.BaseCurrency + target
-			rate, exists := s.fiatcache.GetRateWithDate(date, key)
+			rate, exists := l.rate(FiatRates, date, key)
 			if exists {
 				return rate, nil
 			}
 		}else if target==internal.BaseCurrency{
 			key := internal.BaseCurrency + base
-			rate,exists := s.fiatcache.GetRateWithDate(date,key)
+			rate,exists := l.rate(FiatRates, date,key)
 			if exists{
 				return 1/rate,nil
 			}
 		}else {
 			// Cross-rate calculation for any fiat to any fiat (e.g., EUR to INR).
-			rateUSDTarget, existsTarget := s.fiatcache.GetRateWithDate(date, internal.BaseCurrency+target)
-			rateUSDBase, existsBase := s.fiatcache.GetRateWithDate(date, internal.BaseCurrency+base)
+			rateUSDTarget, existsTarget := l.rate(FiatRates, date, internal.BaseCurrency+target)
+			rateUSDBase, existsBase := l.rate(FiatRates, date, internal.BaseCurrency+base)
 			if existsTarget && existsBase {
 				if rateUSDBase == 0 {
 					return 0, fmt.Errorf("invalid rate for %s, cannot divide by zero", base)
@@ -70,8 +201,8 @@ func (s *ExchangeRateServiceImpl) getRateForCurrencies(base, target, date string
 	// Case 2: Both currencies are crypto.
 	// The rate is (Base->USD) / (Target->USD).
 	if !baseIsFiat && !targetIsFiat {
-		rateBaseUSD, existsBase := s.cryptocache.GetRateWithDate(date, base+internal.BaseCurrency)
-		rateTargetUSD, existsTarget := s.cryptocache.GetRateWithDate(date, target+internal.BaseCurrency)
+		rateBaseUSD, existsBase := l.rate(CryptoRates, date, base+internal.BaseCurrency)
+		rateTargetUSD, existsTarget := l.rate(CryptoRates, date, target+internal.BaseCurrency)
 		if existsBase && existsTarget {
 			if rateTargetUSD == 0 {
 				return 0, fmt.Errorf("invalid rate for %s, cannot divide by zero", target)
@@ -86,14 +217,14 @@ func (s *ExchangeRateServiceImpl) getRateForCurrencies(base, target, date string
 		var rateUSDTarget float64
 		var existsFiat bool
 		if base != internal.BaseCurrency {
-			rateUSDTarget, existsFiat = s.fiatcache.GetRateWithDate(date, internal.BaseCurrency+base)
+			rateUSDTarget, existsFiat = l.rate(FiatRates, date, internal.BaseCurrency+base)
 		}else{
 			rateUSDTarget=1
 			existsFiat=true
 		}
 		
 		
-		rateTargetUSD, existsCrypto := s.cryptocache.GetRateWithDate(date, target+internal.BaseCurrency)
+		rateTargetUSD, existsCrypto := l.rate(CryptoRates, date, target+internal.BaseCurrency)
 		if existsFiat && existsCrypto {
 			if rateUSDTarget == 0 {
 				return 0, fmt.Errorf("invalid rate for %s, cannot divide by zero", base)
@@ -105,13 +236,13 @@ func (s *ExchangeRateServiceImpl) getRateForCurrencies(base, target, date string
 	// Case 4: Mixed currencies (crypto to fiat).
 	
 	if !baseIsFiat && targetIsFiat {
-		rateBaseUSD, existsCrypto := s.cryptocache.GetRateWithDate(date, base+internal.BaseCurrency)
+		rateBaseUSD, existsCrypto := l.rate(CryptoRates, date, base+internal.BaseCurrency)
 		
 		if target != internal.BaseCurrency {
-		rateUSDTarget, existsFiat := s.fiatcache.GetRateWithDate(date, internal.BaseCurrency+target)
+		rateUSDTarget, existsFiat := l.rate(FiatRates, date, internal.BaseCurrency+target)
 		if existsCrypto && existsFiat {
 			return rateBaseUSD * rateUSDTarget, nil
-		}}else{
+		}}else if existsCrypto {
 			return rateBaseUSD,nil
 		}
 		
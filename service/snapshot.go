@@ -0,0 +1,46 @@
+package service
+
+import (
+	"github.com/pavankalyan767/exchange-rate-service/snapshot"
+	"github.com/pavankalyan767/exchange-rate-service/store"
+)
+
+// Export returns a snapshot of every stored rate map or, without a store, of
+// the rate maps currently cached, whose fetch times are then unknown.
+func (rf *RateFetcher) Export() (*snapshot.Snapshot, error) {
+	if rf.store != nil {
+		return snapshot.FromStore(rf.store)
+	}
+
+	snap := snapshot.New()
+	for _, kind := range []string{FiatRates, CryptoRates} {
+		rates := rf.cacheFor(kind)
+		for _, date := range rates.Keys() {
+			dayRates, ok := rates.Get(date)
+			if !ok {
+				continue
+			}
+			source, _ := rf.Source(kind, date)
+			snap.Entries = append(snap.Entries, snapshot.Entry{Kind: kind, Date: date, Rates: dayRates, Source: source})
+		}
+	}
+	snap.Sort()
+	return snap, nil
+}
+
+// Import caches and stores every entry of snap as one version, replacing the
+// rate maps of the same kind and date, and returns the number of entries
+// imported. Imported live rates for today are replaced at the next poll.
+func (rf *RateFetcher) Import(snap *snapshot.Snapshot) (int, error) {
+	if err := snap.Validate(); err != nil {
+		return 0, err
+	}
+
+	var b batch
+	for _, entry := range snap.Entries {
+		b.put(entry.Kind, entry.Date, store.Record{Rates: entry.Rates, Source: entry.Source, FetchedAt: entry.FetchedAt}, rf.historyTTL())
+	}
+	rf.publish(&b)
+	rf.logger.Log("message", "Snapshot imported", "entries", len(snap.Entries))
+	return len(snap.Entries), nil
+}
@@ -0,0 +1,250 @@
+// Package snapshot defines the archive format used to move rate maps between
+// environments, as JSON or CSV, and copies archives out of and into a store.
+//
+// A JSON archive holds the format version, its creation time and one entry per
+// rate kind and date. A CSV archive holds one row per rate, with the columns
+// version, kind, date, pair, rate, source and fetched_at.
+package snapshot
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pavankalyan767/exchange-rate-service/currency"
+	"github.com/pavankalyan767/exchange-rate-service/store"
+	"github.com/pavankalyan767/exchange-rate-service/types"
+)
+
+// Version is the archive format version written by this package. Archives of a
+// newer version are rejected.
+const Version = 1
+
+// Format is an archive encoding.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+)
+
+// ParseFormat validates a format name. The empty string selects the format
+// matching the extension of path, or JSON when that is not .csv.
+func ParseFormat(format, path string) (Format, error) {
+	switch Format(format) {
+	case FormatJSON, FormatCSV:
+		return Format(format), nil
+	case "":
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			return FormatCSV, nil
+		}
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("invalid format %q: must be json or csv", format)
+	}
+}
+
+// Entry is one day's rate map of one kind.
+type Entry struct {
+	Kind      string          `json:"kind"`
+	Date      string          `json:"date"`
+	Rates     types.RateTable `json:"rates"`
+	Source    string          `json:"source"`
+	FetchedAt time.Time       `json:"fetched_at"`
+}
+
+// Snapshot is a versioned archive of rate maps.
+type Snapshot struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Entries   []Entry   `json:"entries"`
+}
+
+// New returns an empty snapshot of the current version.
+func New() *Snapshot {
+	return &Snapshot{Version: Version, CreatedAt: time.Now().UTC(), Entries: []Entry{}}
+}
+
+// Sort orders the entries by kind, then date.
+func (s *Snapshot) Sort() {
+	slices.SortFunc(s.Entries, func(a, b Entry) int {
+		if c := strings.Compare(a.Kind, b.Kind); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Date, b.Date)
+	})
+}
+
+// Validate checks the version and that every entry has a kind, a valid date and rates.
+func (s *Snapshot) Validate() error {
+	if s.Version < 1 || s.Version > Version {
+		return fmt.Errorf("unsupported snapshot version %d: this build reads versions 1 to %d", s.Version, Version)
+	}
+	for i, entry := range s.Entries {
+		if entry.Kind == "" {
+			return fmt.Errorf("entry %d: missing kind", i)
+		}
+		// The kind names the store bucket the entry is written to.
+		if kind := currency.Kind(entry.Kind); kind != currency.Fiat && kind != currency.Crypto {
+			return fmt.Errorf("entry %d: unknown kind %q: must be fiat or crypto", i, entry.Kind)
+		}
+		if _, err := time.Parse(dateFormat, entry.Date); err != nil {
+			return fmt.Errorf("entry %d: invalid date %q", i, entry.Date)
+		}
+		if len(entry.Rates) == 0 {
+			return fmt.Errorf("entry %d (%s %s): no rates", i, entry.Kind, entry.Date)
+		}
+	}
+	return nil
+}
+
+const dateFormat = "2006-01-02"
+
+var csvHeader = []string{"version", "kind", "date", "pair", "rate", "source", "fetched_at"}
+
+// Write encodes s to w in the given format.
+func Write(w io.Writer, format Format, s *Snapshot) error {
+	if format == FormatCSV {
+		return writeCSV(w, s)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// Read decodes and validates a snapshot from r in the given format.
+func Read(r io.Reader, format Format) (*Snapshot, error) {
+	var s *Snapshot
+	var err error
+	if format == FormatCSV {
+		s, err = readCSV(r)
+	} else {
+		s = &Snapshot{}
+		err = json.NewDecoder(r).Decode(s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s snapshot: %w", format, err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func writeCSV(w io.Writer, s *Snapshot) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(csvHeader); err != nil {
+		return err
+	}
+	version := strconv.Itoa(s.Version)
+	for _, entry := range s.Entries {
+		pairs := make([]string, 0, len(entry.Rates))
+		for pair := range entry.Rates {
+			pairs = append(pairs, pair)
+		}
+		slices.Sort(pairs)
+
+		fetchedAt := entry.FetchedAt.UTC().Format(time.RFC3339)
+		for _, pair := range pairs {
+			rate := strconv.FormatFloat(entry.Rates[pair], 'g', -1, 64)
+			if err := out.Write([]string{version, entry.Kind, entry.Date, pair, rate, entry.Source, fetchedAt}); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func readCSV(r io.Reader) (*Snapshot, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = len(csvHeader)
+
+	header, err := in.Read()
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Equal(header, csvHeader) {
+		return nil, fmt.Errorf("unexpected header %v", header)
+	}
+
+	s := &Snapshot{Version: Version, Entries: []Entry{}}
+	index := map[[2]string]int{}
+	for {
+		row, err := in.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		version, err := strconv.Atoi(row[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", row[0])
+		}
+		s.Version = max(s.Version, version)
+		rate, err := strconv.ParseFloat(row[4], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate %q for %s on %s", row[4], row[3], row[2])
+		}
+		fetchedAt, err := time.Parse(time.RFC3339, row[6])
+		if err != nil {
+			return nil, fmt.Errorf("invalid fetched_at %q", row[6])
+		}
+
+		key := [2]string{row[1], row[2]}
+		i, ok := index[key]
+		if !ok {
+			i = len(s.Entries)
+			index[key] = i
+			s.Entries = append(s.Entries, Entry{Kind: row[1], Date: row[2], Rates: types.RateTable{}, Source: row[5], FetchedAt: fetchedAt})
+		}
+		s.Entries[i].Rates[row[3]] = rate
+	}
+	return s, nil
+}
+
+// FromStore returns a snapshot of every record in st.
+func FromStore(st *store.Store) (*Snapshot, error) {
+	kinds, err := st.Kinds()
+	if err != nil {
+		return nil, err
+	}
+
+	s := New()
+	for _, kind := range kinds {
+		err := st.ForEach(kind, func(date string, record store.Record) error {
+			s.Entries = append(s.Entries, Entry{Kind: kind, Date: date, Rates: record.Rates, Source: record.Source, FetchedAt: record.FetchedAt})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.Sort()
+	return s, nil
+}
+
+// ToStore writes every entry of s to st in a single transaction, replacing the
+// records of the same kind and date, and returns the number of entries written.
+// Nothing is written if s is invalid or the write fails.
+func ToStore(st *store.Store, s *Snapshot) (int, error) {
+	if err := s.Validate(); err != nil {
+		return 0, err
+	}
+	entries := make([]store.Entry, len(s.Entries))
+	for i, entry := range s.Entries {
+		entries[i] = store.Entry{Kind: entry.Kind, Date: entry.Date, Record: store.Record{Rates: entry.Rates, Source: entry.Source, FetchedAt: entry.FetchedAt}}
+	}
+	if err := st.PutAll(entries); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
@@ -0,0 +1,87 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavankalyan767/exchange-rate-service/snapshot"
+	"github.com/pavankalyan767/exchange-rate-service/store"
+)
+
+func testSnapshot() *snapshot.Snapshot {
+	fetchedAt := time.Date(2025, 8, 14, 12, 30, 0, 0, time.UTC)
+	snap := snapshot.New()
+	snap.Entries = []snapshot.Entry{
+		{Kind: "crypto", Date: "2025-08-14", Rates: map[string]float64{"BTCUSD": 30000.5, "ETHUSD": 1800}, Source: "coinlayer", FetchedAt: fetchedAt},
+		{Kind: "fiat", Date: "2025-08-13", Rates: map[string]float64{"USDINR": 83.1234}, Source: "consensus:a,b", FetchedAt: fetchedAt},
+		{Kind: "fiat", Date: "2025-08-14", Rates: map[string]float64{"USDEUR": 0.91, "USDINR": 83}, Source: "currencylayer", FetchedAt: fetchedAt},
+	}
+	return snap
+}
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	for _, format := range []snapshot.Format{snapshot.FormatJSON, snapshot.FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			snap := testSnapshot()
+
+			var buf bytes.Buffer
+			if err := snapshot.Write(&buf, format, snap); err != nil {
+				t.Fatalf("expected no error writing, got %v", err)
+			}
+			read, err := snapshot.Read(&buf, format)
+			if err != nil {
+				t.Fatalf("expected no error reading, got %v", err)
+			}
+
+			if read.Version != snapshot.Version {
+				t.Errorf("expected version %d, got %d", snapshot.Version, read.Version)
+			}
+			if !reflect.DeepEqual(read.Entries, snap.Entries) {
+				t.Errorf("entries changed in the round trip:\nwrote %+v\nread  %+v", snap.Entries, read.Entries)
+			}
+		})
+	}
+}
+
+func TestRead_RejectsNewerVersion(t *testing.T) {
+	archive := `{"version": 99, "entries": []}`
+	if _, err := snapshot.Read(strings.NewReader(archive), snapshot.FormatJSON); err == nil {
+		t.Errorf("expected an error for an unsupported version")
+	}
+
+	csvArchive := "version,kind,date,pair,rate,source,fetched_at\n99,fiat,2025-08-14,USDINR,83,x,2025-08-14T00:00:00Z\n"
+	if _, err := snapshot.Read(strings.NewReader(csvArchive), snapshot.FormatCSV); err == nil {
+		t.Errorf("expected an error for an unsupported CSV version")
+	}
+}
+
+func TestToStore_WritesAllOrNothing(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "rates.db"))
+	if err != nil {
+		t.Fatalf("expected no error opening store, got %v", err)
+	}
+	defer st.Close()
+
+	// An entry of an unknown kind, here the store's reserved observations
+	// bucket, rejects the whole archive.
+	snap := testSnapshot()
+	snap.Entries = append(snap.Entries, snapshot.Entry{Kind: "@observations", Date: "2025-08-14", Rates: map[string]float64{"USDINR": 1}})
+	if _, err := snapshot.ToStore(st, snap); err == nil || !strings.Contains(err.Error(), "unknown kind") {
+		t.Errorf("expected an unknown kind error, got %v", err)
+	}
+	if kinds, _ := st.Kinds(); len(kinds) != 0 {
+		t.Errorf("expected nothing to be written, got kinds %v", kinds)
+	}
+
+	written, err := snapshot.ToStore(st, testSnapshot())
+	if err != nil || written != 3 {
+		t.Fatalf("expected 3 entries written, got %d (err %v)", written, err)
+	}
+	if record, ok, _ := st.Get("fiat", "2025-08-13"); !ok || record.Rates["USDINR"] != 83.1234 || record.Source != "consensus:a,b" {
+		t.Errorf("expected the fiat entry of 2025-08-13 to be stored, got %+v (found %v)", record, ok)
+	}
+}
@@ -0,0 +1,302 @@
+// Package store persists rate maps on disk, so that a restart does not lose
+// the history fetched so far. It is backed by bbolt, an embedded pure-Go
+// key/value store: every rate kind gets its own bucket, keyed by date, and the
+// intraday observations of every kind are kept in a bucket of their own, keyed
+// by the instant they were fetched at.
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pavankalyan767/exchange-rate-service/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Record is one day's rate map of one kind, or one intraday observation of
+// it, with where and when it was fetched.
+type Record struct {
+	Rates     types.RateTable `json:"rates"`
+	Source    string          `json:"source"`
+	FetchedAt time.Time       `json:"fetched_at"`
+}
+
+// Store is a durable collection of Records. It is safe for concurrent use.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the store at path, creating the file and its directory if needed.
+// Only one process may hold the store open at a time.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close releases the store file.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Put saves the record of kind for date, replacing any previous one.
+func (s *Store) Put(kind, date string, record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s rates for %s: %w", kind, date, err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(kind))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(date), data)
+	})
+}
+
+// Entry is a Record together with its kind and date, for PutAll.
+type Entry struct {
+	Kind   string
+	Date   string
+	Record Record
+	// Observed also keeps the record as the intraday observation of kind at
+	// its FetchedAt instant, so that it is not lost when the day's record is
+	// replaced by a later poll.
+	Observed bool
+}
+
+// observationsBucket holds a nested bucket of observations per kind. Its name
+// cannot clash with a kind, which is a single word.
+const observationsBucket = "@observations"
+
+// observationKeyFormat is a fixed-width UTC timestamp, so that observation keys
+// sort chronologically as bytes.
+const observationKeyFormat = "2006-01-02T15:04:05.000000000Z"
+
+func observationKey(at time.Time) []byte {
+	return []byte(at.UTC().Format(observationKeyFormat))
+}
+
+// PutAll stores every entry in a single transaction: either all of them are
+// written or none is.
+func (s *Store) PutAll(entries []Entry) error {
+	encoded := make([][]byte, len(entries))
+	for i, entry := range entries {
+		data, err := json.Marshal(entry.Record)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s rates for %s: %w", entry.Kind, entry.Date, err)
+		}
+		encoded[i] = data
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for i, entry := range entries {
+			bucket, err := tx.CreateBucketIfNotExists([]byte(entry.Kind))
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(entry.Date), encoded[i]); err != nil {
+				return err
+			}
+			if !entry.Observed {
+				continue
+			}
+			observations, err := createObservationBucket(tx, entry.Kind)
+			if err != nil {
+				return err
+			}
+			if err := observations.Put(observationKey(entry.Record.FetchedAt), encoded[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func createObservationBucket(tx *bolt.Tx, kind string) (*bolt.Bucket, error) {
+	parent, err := tx.CreateBucketIfNotExists([]byte(observationsBucket))
+	if err != nil {
+		return nil, err
+	}
+	return parent.CreateBucketIfNotExists([]byte(kind))
+}
+
+func observationBucket(tx *bolt.Tx, kind string) *bolt.Bucket {
+	parent := tx.Bucket([]byte(observationsBucket))
+	if parent == nil {
+		return nil
+	}
+	return parent.Bucket([]byte(kind))
+}
+
+// ObservationAt returns the latest observation of kind fetched at or before at,
+// on the same day as at in at's location; ok is false if there is none, so an
+// observation from an earlier day is never returned. The observation's instant
+// is its FetchedAt.
+func (s *Store) ObservationAt(kind string, at time.Time) (record Record, ok bool, err error) {
+	dayStart := observationKey(time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location()))
+	err = s.db.View(func(tx *bolt.Tx) error {
+		bucket := observationBucket(tx, kind)
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		key := observationKey(at)
+		found, data := cursor.Seek(key)
+		if !bytes.Equal(found, key) {
+			// Seek lands on the first observation after at, or past the end.
+			found, data = cursor.Prev()
+		}
+		if found == nil || bytes.Compare(found, dayStart) < 0 {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(data, &record)
+	})
+	if err != nil {
+		return Record{}, false, fmt.Errorf("failed to read %s observation at %s: %w", kind, at.Format(time.RFC3339), err)
+	}
+	return record, ok, nil
+}
+
+// DeleteObservationsBefore removes the observations of kind fetched before t
+// and returns how many were removed.
+func (s *Store) DeleteObservationsBefore(kind string, t time.Time) (int, error) {
+	deleted := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := observationBucket(tx, kind)
+		if bucket == nil {
+			return nil
+		}
+		cutoff := string(observationKey(t))
+		var expired [][]byte
+		cursor := bucket.Cursor()
+		for key, _ := cursor.First(); key != nil && string(key) < cutoff; key, _ = cursor.Next() {
+			expired = append(expired, append([]byte(nil), key...))
+		}
+		for _, key := range expired {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		deleted = len(expired)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete %s observations before %s: %w", kind, t.Format(time.RFC3339), err)
+	}
+	return deleted, nil
+}
+
+// Get returns the record of kind for date; ok is false if none is stored.
+func (s *Store) Get(kind, date string) (record Record, ok bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(kind))
+		if bucket == nil {
+			return nil
+		}
+		data := bucket.Get([]byte(date))
+		if data == nil {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(data, &record)
+	})
+	if err != nil {
+		return Record{}, false, fmt.Errorf("failed to read %s rates for %s: %w", kind, date, err)
+	}
+	return record, ok, nil
+}
+
+// Dates returns the dates stored for kind, in ascending order.
+func (s *Store) Dates(kind string) ([]string, error) {
+	var dates []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(kind))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, _ []byte) error {
+			dates = append(dates, string(key))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s dates: %w", kind, err)
+	}
+	return dates, nil
+}
+
+// Kinds returns the rate kinds that have records, in ascending order.
+func (s *Store) Kinds() ([]string, error) {
+	var kinds []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if string(name) == observationsBucket {
+				return nil
+			}
+			kinds = append(kinds, string(name))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list kinds: %w", err)
+	}
+	return kinds, nil
+}
+
+// ForEach calls fn for every record of kind, in ascending date order, stopping
+// at the first error fn returns.
+func (s *Store) ForEach(kind string, fn func(date string, record Record) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(kind))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, data []byte) error {
+			var record Record
+			if err := json.Unmarshal(data, &record); err != nil {
+				return fmt.Errorf("failed to decode %s rates for %s: %w", kind, key, err)
+			}
+			return fn(string(key), record)
+		})
+	})
+}
+
+// DeleteBefore removes the records of kind dated before date and returns how
+// many were removed. Dates are compared as strings, which orders them
+// chronologically in the 2006-01-02 format.
+func (s *Store) DeleteBefore(kind, date string) (int, error) {
+	deleted := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(kind))
+		if bucket == nil {
+			return nil
+		}
+		// Collect the keys first: deleting while iterating a cursor skips entries.
+		var expired [][]byte
+		cursor := bucket.Cursor()
+		for key, _ := cursor.First(); key != nil && string(key) < date; key, _ = cursor.Next() {
+			expired = append(expired, append([]byte(nil), key...))
+		}
+		for _, key := range expired {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		deleted = len(expired)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete %s rates before %s: %w", kind, date, err)
+	}
+	return deleted, nil
+}
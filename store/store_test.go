@@ -0,0 +1,103 @@
+package store_test
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pavankalyan767/exchange-rate-service/store"
+)
+
+func TestStore_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.db")
+
+	st, err := store.Open(path)
+	if err != nil {
+		t.Fatalf("expected no error opening store, got %v", err)
+	}
+	fetchedAt := time.Date(2025, 8, 14, 12, 0, 0, 0, time.UTC)
+	for _, date := range []string{"2025-08-12", "2025-08-13", "2025-08-14"} {
+		record := store.Record{Rates: map[string]float64{"USDINR": 83.0}, Source: "currencylayer", FetchedAt: fetchedAt}
+		if err := st.Put("fiat", date, record); err != nil {
+			t.Fatalf("expected no error storing %s, got %v", date, err)
+		}
+	}
+	if err := st.Close(); err != nil {
+		t.Fatalf("expected no error closing store, got %v", err)
+	}
+
+	st, err = store.Open(path)
+	if err != nil {
+		t.Fatalf("expected no error reopening store, got %v", err)
+	}
+	defer st.Close()
+
+	record, ok, err := st.Get("fiat", "2025-08-13")
+	if err != nil || !ok {
+		t.Fatalf("expected stored record, got ok=%v err=%v", ok, err)
+	}
+	if record.Rates["USDINR"] != 83.0 || record.Source != "currencylayer" || !record.FetchedAt.Equal(fetchedAt) {
+		t.Errorf("unexpected record %+v", record)
+	}
+	if _, ok, _ := st.Get("crypto", "2025-08-13"); ok {
+		t.Errorf("expected kinds to be stored separately")
+	}
+
+	deleted, err := st.DeleteBefore("fiat", "2025-08-14")
+	if err != nil || deleted != 2 {
+		t.Fatalf("expected 2 records deleted, got %d (err %v)", deleted, err)
+	}
+	dates, err := st.Dates("fiat")
+	if err != nil {
+		t.Fatalf("expected no error listing dates, got %v", err)
+	}
+	if !slices.Equal(dates, []string{"2025-08-14"}) {
+		t.Errorf("expected only 2025-08-14 to remain, got %v", dates)
+	}
+}
+
+func TestStore_ObservationAtReturnsNearestEarlier(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "rates.db"))
+	if err != nil {
+		t.Fatalf("expected no error opening store, got %v", err)
+	}
+	defer st.Close()
+
+	start := time.Date(2025, 8, 14, 9, 0, 0, 0, time.UTC)
+	var entries []store.Entry
+	for hour := range 3 {
+		fetchedAt := start.Add(time.Duration(hour) * time.Hour)
+		record := store.Record{Rates: map[string]float64{"USDINR": 83.0 + float64(hour)}, Source: "currencylayer", FetchedAt: fetchedAt}
+		entries = append(entries, store.Entry{Kind: "fiat", Date: "2025-08-14", Record: record, Observed: true})
+	}
+	if err := st.PutAll(entries); err != nil {
+		t.Fatalf("expected no error storing observations, got %v", err)
+	}
+
+	for at, expected := range map[time.Time]float64{
+		start:                       83.0,
+		start.Add(90 * time.Minute): 84.0,
+		start.Add(2 * time.Hour):    85.0,
+		start.Add(14 * time.Hour):   85.0,
+		start.In(time.FixedZone("IST", 19800)).Add(time.Hour): 84.0,
+	} {
+		record, ok, err := st.ObservationAt("fiat", at)
+		if err != nil || !ok || record.Rates["USDINR"] != expected {
+			t.Errorf("expected USDINR %.2f at %s, got %v (found %v, err %v)", expected, at, record.Rates, ok, err)
+		}
+	}
+	if _, ok, _ := st.ObservationAt("fiat", start.Add(-time.Second)); ok {
+		t.Error("expected no observation before the first one")
+	}
+	if _, ok, _ := st.ObservationAt("fiat", start.Add(48*time.Hour)); ok {
+		t.Error("expected no observation from an earlier day")
+	}
+
+	if kinds, _ := st.Kinds(); !slices.Equal(kinds, []string{"fiat"}) {
+		t.Errorf("expected only the fiat kind, got %v", kinds)
+	}
+	if deleted, err := st.DeleteObservationsBefore("fiat", start.Add(time.Hour)); err != nil || deleted != 1 {
+		t.Errorf("expected 1 observation deleted, got %d (err %v)", deleted, err)
+	}
+}
@@ -17,12 +17,12 @@ func ConvertEndpoint(svc service.ExchangeRateService) endpoint.Endpoint {
 		req := request.(types.ConvertRequest)
 		ctx := context.Background()
 		
-		amount, err := svc.Convert(ctx, &req)
+		amount, freshness, err := svc.Convert(ctx, &req)
 		if err != nil {
 			a := &types.ConvertResponse{ConvertedAmount: amount, Error: err.Error()}
 			return a, nil
 		}
-		return types.ConvertResponse{ConvertedAmount: amount}, nil
+		return types.ConvertResponse{ConvertedAmount: amount, AgeSeconds: freshness.Age.Seconds(), Stale: freshness.Stale, Version: freshness.Version, ObservedAt: observedAt(freshness)}, nil
 	}
 }
 
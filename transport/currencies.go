@@ -0,0 +1,16 @@
+package transport
+
+import (
+	"net/http"
+
+	"github.com/pavankalyan767/exchange-rate-service/currency"
+	"github.com/pavankalyan767/exchange-rate-service/types"
+)
+
+// NewCurrenciesHandler returns a handler listing the accepted currencies with their metadata.
+func NewCurrenciesHandler(registry *currency.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		EncodeResponse(r.Context(), w, types.CurrenciesResponse{Currencies: registry.All()})
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
 	"github.com/gorilla/schema"
@@ -15,15 +16,24 @@ func FetchEndpoint(svc service.ExchangeRateService) endpoint.Endpoint {
 	return func(_ context.Context, request interface{}) (interface{}, error) {
 		req := request.(types.FetchRateRequest)
 		ctx := context.Background()
-		rate, err := svc.FetchRate(ctx, &req)
+		rate, freshness, err := svc.FetchRate(ctx, &req)
 		if err != nil {
 			a := &types.FetchRateResponse{Rate: rate, Error: err.Error()}
 			return a, nil
 		}
-		return types.FetchRateResponse{rate, ""}, nil
+		return types.FetchRateResponse{Rate: rate, AgeSeconds: freshness.Age.Seconds(), Stale: freshness.Stale, Version: freshness.Version, ObservedAt: observedAt(freshness)}, nil
 	}
 }
 
+// observedAt formats the instant of the observation a rate was read from, or
+// returns "" when the rate was looked up by date.
+func observedAt(freshness types.Freshness) string {
+	if freshness.ObservedAt.IsZero() {
+		return ""
+	}
+	return freshness.ObservedAt.UTC().Format(time.RFC3339)
+}
+
 func DecodeFetchRateRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var request types.FetchRateRequest
 
@@ -14,12 +14,12 @@ func HistoryEndpoint(svc service.ExchangeRateService) endpoint.Endpoint {
 	return func(_ context.Context, request interface{}) (interface{}, error) {
 		req := request.(types.HistoryRequest)
 		ctx := context.Background()
-		rates, err := svc.History(ctx, &req)
+		rates, freshness, err := svc.History(ctx, &req)
 		if err != nil {
 			a := &types.HistoryResponse{Rates: rates, Error: err.Error()}
 			return a, nil
 		}
-		return types.HistoryResponse{Rates: rates}, nil
+		return types.HistoryResponse{Rates: rates, AgeSeconds: freshness.Age.Seconds(), Stale: freshness.Stale, Version: freshness.Version}, nil
 	}
 }
 
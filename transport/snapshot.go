@@ -0,0 +1,83 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+
+	"github.com/pavankalyan767/exchange-rate-service/snapshot"
+	"github.com/pavankalyan767/exchange-rate-service/types"
+)
+
+// maxSnapshotSize bounds the body of a snapshot import.
+const maxSnapshotSize = 64 << 20
+
+// SnapshotService exports and imports rate snapshots. It is implemented by service.RateFetcher.
+type SnapshotService interface {
+	Export() (*snapshot.Snapshot, error)
+	Import(snap *snapshot.Snapshot) (int, error)
+}
+
+// NewSnapshotHandler serves the rate snapshot of a running service: GET exports
+// it and POST imports one. The format query parameter selects json (the default)
+// or csv. Requests must send token as a bearer token; when token is empty the
+// endpoint is disabled and every request is forbidden.
+func NewSnapshotHandler(svc SnapshotService, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http.Error(w, "admin endpoints are disabled: ADMIN_TOKEN is not set", http.StatusForbidden)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		format, err := snapshot.ParseFormat(r.URL.Query().Get("format"), "")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			snap, err := svc.Export()
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to export snapshot: %v", err), http.StatusInternalServerError)
+				return
+			}
+			// Encode the whole archive first, so that a failure can still be reported.
+			var body bytes.Buffer
+			if err := snapshot.Write(&body, format, snap); err != nil {
+				http.Error(w, fmt.Sprintf("failed to encode snapshot: %v", err), http.StatusInternalServerError)
+				return
+			}
+			if format == snapshot.FormatCSV {
+				w.Header().Set("Content-Type", "text/csv")
+			} else {
+				w.Header().Set("Content-Type", "application/json")
+			}
+			w.Write(body.Bytes())
+
+		case http.MethodPost:
+			w.Header().Set("Content-Type", "application/json")
+			snap, err := snapshot.Read(http.MaxBytesReader(w, r.Body, maxSnapshotSize), format)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				EncodeResponse(r.Context(), w, types.SnapshotImportResponse{Error: err.Error()})
+				return
+			}
+			imported, err := svc.Import(snap)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				EncodeResponse(r.Context(), w, types.SnapshotImportResponse{Error: err.Error()})
+				return
+			}
+			EncodeResponse(r.Context(), w, types.SnapshotImportResponse{Imported: imported})
+
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package transport_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavankalyan767/exchange-rate-service/snapshot"
+	"github.com/pavankalyan767/exchange-rate-service/transport"
+	"github.com/pavankalyan767/exchange-rate-service/types"
+)
+
+// memorySnapshots is a SnapshotService holding the last imported snapshot.
+type memorySnapshots struct {
+	snap *snapshot.Snapshot
+}
+
+func (m *memorySnapshots) Export() (*snapshot.Snapshot, error) {
+	return m.snap, nil
+}
+
+func (m *memorySnapshots) Import(snap *snapshot.Snapshot) (int, error) {
+	m.snap = snap
+	return len(snap.Entries), nil
+}
+
+func newTestSnapshot() *snapshot.Snapshot {
+	snap := snapshot.New()
+	snap.Entries = append(snap.Entries, snapshot.Entry{
+		Kind:      "fiat",
+		Date:      "2025-08-14",
+		Rates:     types.RateTable{"USDINR": 83.0},
+		Source:    "currencylayer",
+		FetchedAt: time.Date(2025, 8, 14, 12, 0, 0, 0, time.UTC),
+	})
+	return snap
+}
+
+func serveSnapshot(handler http.Handler, method, token, format, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/admin/snapshot?format="+format, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestSnapshotHandler_RequiresToken(t *testing.T) {
+	svc := &memorySnapshots{snap: newTestSnapshot()}
+
+	// Without a configured token the endpoint is disabled altogether.
+	if w := serveSnapshot(transport.NewSnapshotHandler(svc, ""), http.MethodGet, "", "json", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without a configured token, got %d", w.Code)
+	}
+
+	handler := transport.NewSnapshotHandler(svc, "secret")
+	for _, token := range []string{"", "wrong"} {
+		if w := serveSnapshot(handler, http.MethodGet, token, "json", ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401 with token %q, got %d", token, w.Code)
+		}
+	}
+}
+
+func TestSnapshotHandler_ExportsAndImports(t *testing.T) {
+	svc := &memorySnapshots{snap: newTestSnapshot()}
+	handler := transport.NewSnapshotHandler(svc, "secret")
+
+	for _, format := range []string{"json", "csv"} {
+		w := serveSnapshot(handler, http.MethodGet, "secret", format, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200 exporting %s, got %d: %s", format, w.Code, w.Body)
+		}
+		exported, err := snapshot.Read(w.Body, snapshot.Format(format))
+		if err != nil {
+			t.Fatalf("expected a valid %s archive, got %v", format, err)
+		}
+		if len(exported.Entries) != 1 || exported.Entries[0].Rates["USDINR"] != 83.0 {
+			t.Errorf("expected the exported %s archive to hold USDINR 83.00, got %+v", format, exported.Entries)
+		}
+	}
+
+	// A bad archive is rejected and leaves the service untouched.
+	w := serveSnapshot(handler, http.MethodPost, "secret", "json", `{"version": 1, "entries": [{"kind": "fiat", "date": "yesterday"}]}`)
+	var response types.SnapshotImportResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil || w.Code != http.StatusBadRequest || response.Error == "" {
+		t.Errorf("expected 400 with an error for a bad archive, got %d %+v (err %v)", w.Code, response, err)
+	}
+	if svc.snap.Entries[0].Rates["USDINR"] != 83.0 {
+		t.Error("expected a bad archive not to be imported")
+	}
+
+	var body strings.Builder
+	imported := newTestSnapshot()
+	imported.Entries[0].Rates = types.RateTable{"USDINR": 84.0}
+	if err := snapshot.Write(&body, snapshot.FormatJSON, imported); err != nil {
+		t.Fatalf("expected no error encoding, got %v", err)
+	}
+	w = serveSnapshot(handler, http.MethodPost, "secret", "json", body.String())
+	response = types.SnapshotImportResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil || w.Code != http.StatusOK || response.Imported != 1 {
+		t.Errorf("expected 1 entry imported, got %d %+v (err %v)", w.Code, response, err)
+	}
+	if svc.snap.Entries[0].Rates["USDINR"] != 84.0 {
+		t.Errorf("expected the imported USDINR 84.00, got %v", svc.snap.Entries[0].Rates)
+	}
+}
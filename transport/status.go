@@ -0,0 +1,36 @@
+package transport
+
+import (
+	"net/http"
+
+	"github.com/pavankalyan767/exchange-rate-service/client"
+	"github.com/pavankalyan767/exchange-rate-service/types"
+)
+
+// UpstreamClient pairs an upstream API client with the kind of rates it serves.
+type UpstreamClient struct {
+	Kind   string
+	Client *client.APIClient
+}
+
+// NewStatusHandler returns a handler reporting the circuit breaker state of every upstream provider.
+func NewStatusHandler(upstreams []UpstreamClient) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := types.StatusResponse{Providers: []types.ProviderStatus{}}
+		for _, upstream := range upstreams {
+			status := types.ProviderStatus{
+				Name:    upstream.Client.Name(),
+				Kind:    upstream.Kind,
+				Breaker: "disabled",
+			}
+			if breaker := upstream.Client.Breaker(); breaker != nil {
+				status.Breaker = breaker.State().String()
+				status.ConsecutiveFailures = breaker.Failures()
+			}
+			response.Providers = append(response.Providers, status)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		EncodeResponse(r.Context(), w, response)
+	})
+}
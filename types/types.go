@@ -1,15 +1,26 @@
 package types
 
+import (
+	"time"
+
+	"github.com/pavankalyan767/exchange-rate-service/currency"
+)
+
 // FetchFiatRate types
 type FetchRateRequest struct {
 	BaseCurrency   string `json:"base_currency" schema:"base_currency"`
-	TargetCurrency string `json:"target_currency"schema:"target_currency"`
+	TargetCurrency string `json:"target_currency" schema:"target_currency"`
 	Date           string `json:"date"`
+	At             string `json:"at" schema:"at"` // RFC 3339 instant, instead of Date
 }
 
 type FetchRateResponse struct {
-	Rate  float64 `json:"rate"`
-	Error string  `json:"err,omitempty"`
+	Rate       float64 `json:"rate"`
+	AgeSeconds float64 `json:"age_seconds,omitempty"`
+	Stale      bool    `json:"stale,omitempty"`
+	Version    uint64  `json:"version,omitempty"`
+	ObservedAt string  `json:"observed_at,omitempty"`
+	Error      string  `json:"err,omitempty"`
 }
 
 // Convert types
@@ -17,28 +28,100 @@ type ConvertRequest struct {
 	BaseCurrency   string  `json:"base_currency" schema:"base_currency"`
 	TargetCurrency string  `json:"target_currency" schema:"target_currency"`
 	Date           string  `json:"date" schema:"date"`
+	At             string  `json:"at" schema:"at"` // RFC 3339 instant, instead of Date
 	Amount         float64 `json:"amount" schema:"amount"`
 }
 
 // ConvertFiatResponse defines the structure for a currency conversion response.
 type ConvertResponse struct {
 	ConvertedAmount float64 `json:"convertedAmount"`
+	AgeSeconds      float64 `json:"age_seconds,omitempty"`
+	Stale           bool    `json:"stale,omitempty"`
+	Version         uint64  `json:"version,omitempty"`
+	ObservedAt      string  `json:"observed_at,omitempty"`
 	Error           string  `json:"error,omitempty"`
 }
 
+// Freshness describes the rates a result was derived from. When several rates
+// were combined, it describes the oldest of them.
+type Freshness struct {
+	// Age is the time since the rates were fetched from upstream.
+	Age time.Duration
+	// Stale is set when a rate was past its TTL and served while being refreshed.
+	Stale bool
+	// Version is the published version of the rates the result was computed from.
+	Version uint64
+	// ObservedAt is, for a lookup at an instant, when the oldest observation
+	// used was fetched. It is zero otherwise.
+	ObservedAt time.Time
+}
+
+// Add folds the freshness of another rate into f.
+func (f *Freshness) Add(age time.Duration, stale bool) {
+	f.Age = max(f.Age, age)
+	f.Stale = f.Stale || stale
+}
+
+// Observe folds the instant of another observation into f.
+func (f *Freshness) Observe(at time.Time) {
+	if f.ObservedAt.IsZero() || at.Before(f.ObservedAt) {
+		f.ObservedAt = at
+	}
+}
+
 // History types
 type HistoryRequest struct {
 	BaseCurrency   string `json:"base_currency" schema:"base_currency"`
 	TargetCurrency string `json:"target_currency" schema:"target_currency"`
 	From           string `json:"from" schema:"from"`
-	To             string `json:"to"schema:"to"`
+	To             string `json:"to" schema:"to"`
 }
 
 type HistoryResponse struct {
-	Rates map[string]float64 `json:"rates"`
-	Error string             `json:"err,omitempty"`
+	Rates      map[string]float64 `json:"rates"`
+	AgeSeconds float64            `json:"age_seconds,omitempty"`
+	Stale      bool               `json:"stale,omitempty"`
+	Version    uint64             `json:"version,omitempty"`
+	Error      string             `json:"err,omitempty"`
 }
 
+// Status types
+type ProviderStatus struct {
+	Name                string `json:"name"`
+	Kind                string `json:"kind"`
+	Breaker             string `json:"breaker"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+}
 
+type StatusResponse struct {
+	Providers []ProviderStatus `json:"providers"`
+}
 
+type CurrenciesResponse struct {
+	Currencies []currency.Currency `json:"currencies"`
+}
+
+type SnapshotImportResponse struct {
+	Imported int    `json:"imported"`
+	Error    string `json:"error,omitempty"`
+}
 
+// RateTable holds one day's rates of one kind, keyed by currency pair
+// (e.g. "USDINR" for fiat, "BTCUSD" for crypto).
+type RateTable map[string]float64
+
+// Rate returns the rate of pair; ok is false if the table has none.
+func (t RateTable) Rate(pair string) (rate float64, ok bool) {
+	rate, ok = t[pair]
+	return rate, ok
+}
+
+// Size estimates the memory held by the table in bytes: the map header and, per
+// pair, a bucket slot for the key and rate plus the key's bytes.
+func (t RateTable) Size() int {
+	size := 48
+	for pair := range t {
+		size += 16 + 8 + 8 + len(pair)
+	}
+	return size
+}